@@ -33,6 +33,13 @@ type config struct {
 	DBFile          string `env:"DOLLA_BACKEND_DB_FILE"           envDefault:"db.sqlite3"`
 	GinMode         string `env:"DOLLA_BACKEND_GIN_MODE"          envDefault:"release"`
 	PDFExtractorURL string `env:"DOLLA_BACKEND_PDF_EXTRACTOR_URL" envDefault:"http://localhost:9000/extract"`
+	ImportWorkers   int    `env:"DOLLA_BACKEND_IMPORT_WORKERS"    envDefault:"2"`
+
+	ExtractorTimeout          time.Duration `env:"DOLLA_BACKEND_PDF_EXTRACTOR_TIMEOUT"           envDefault:"2m"`
+	ExtractorMaxRetries       int           `env:"DOLLA_BACKEND_PDF_EXTRACTOR_MAX_RETRIES"       envDefault:"2"`
+	ExtractorRetryBackoff     time.Duration `env:"DOLLA_BACKEND_PDF_EXTRACTOR_RETRY_BACKOFF"     envDefault:"1s"`
+	ExtractorBreakerThreshold int           `env:"DOLLA_BACKEND_PDF_EXTRACTOR_BREAKER_THRESHOLD" envDefault:"5"`
+	ExtractorBreakerCooldown  time.Duration `env:"DOLLA_BACKEND_PDF_EXTRACTOR_BREAKER_COOLDOWN"  envDefault:"30s"`
 }
 
 func main() {
@@ -50,6 +57,11 @@ func main() {
 
 		return
 	}
+	if cfg.ImportWorkers < 1 {
+		log.Printf("invalid import workers %d: at least one worker is needed to process uploads", cfg.ImportWorkers)
+
+		return
+	}
 
 	var level slog.Level
 	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
@@ -71,14 +83,24 @@ func main() {
 
 	slog.Info("successfully connected to sqlite3 database")
 
-	svc := dolla.NewService(repo, cfg.PDFExtractorURL)
+	extractor := dolla.NewExtractorClient(dolla.ExtractorConfig{
+		URL:              cfg.PDFExtractorURL,
+		Timeout:          cfg.ExtractorTimeout,
+		MaxRetries:       cfg.ExtractorMaxRetries,
+		RetryBackoff:     cfg.ExtractorRetryBackoff,
+		BreakerThreshold: cfg.ExtractorBreakerThreshold,
+		BreakerCooldown:  cfg.ExtractorBreakerCooldown,
+	})
+
+	svc := dolla.NewService(repo, extractor, dolla.DefaultParsers())
 
 	gin.SetMode(cfg.GinMode)
 
 	router := gin.New()
 	router.Use(cors.Default())
 	router.Use(gin.Recovery())
-	router.Use(sloggin.New(logger))
+	// Daraja callback paths carry the shortcode's secret, so they are kept out of the request logs.
+	router.Use(sloggin.NewWithFilters(logger, sloggin.IgnorePathPrefix("/daraja/")))
 	router.MaxMultipartMemory = maxMultipartMemory
 
 	router = api.NewHandler(svc, router)
@@ -98,6 +120,12 @@ func main() {
 		return nil
 	})
 
+	g.Go(func() error {
+		slog.Info("starting import workers", slog.Int("workers", cfg.ImportWorkers))
+
+		return svc.ProcessImports(ctx, cfg.ImportWorkers)
+	})
+
 	g.Go(func() error {
 		select {
 		case <-ctx.Done():
@@ -105,6 +133,9 @@ func main() {
 		case sig := <-sigChan:
 			slog.Info("received shutdown signal", slog.String("signal", sig.String()))
 
+			// Stop the import workers once the server no longer accepts uploads.
+			defer cancel()
+
 			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 			defer shutdownCancel()
 
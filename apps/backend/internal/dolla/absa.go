@@ -0,0 +1,139 @@
+package dolla
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ABSA current account statements use the common seven column layout read by parseBankPage.
+var absaDateLayouts = []string{ //nolint:gochecknoglobals
+	"02/01/2006", "02 Jan 2006", "02-Jan-2006", "02-01-2006", time.DateOnly,
+}
+
+// The due date and minimum payment are printed once, in the card statement summary.
+var ( //nolint:gochecknoglobals
+	absaDueDate        = regexp.MustCompile(`PAYMENT DUE DATE[:\s]*(\d{1,2}[/\-. ]\w{2,3}[/\-. ]\d{2,4})`)
+	absaMinimumPayment = regexp.MustCompile(`MINIMUM (?:PAYMENT|AMOUNT) DUE[:\s]*(?:KES|KSH)?\s*([\d,]+\.\d{2})`)
+)
+
+type absaParser struct{}
+
+func (absaParser) Name() string {
+	return "ABSA Bank Statement"
+}
+
+func (absaParser) Detect(pages []ExtractionResponse) bool {
+	title := bankStatementTitle(pages)
+
+	return strings.Contains(title, "ABSA") && !isAbsaCardStatement(title) && hasValueDateHeader(pages)
+}
+
+func (absaParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseBankPage(pages[i], newBankLayout(absaDateLayouts), pack))
+	}
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
+
+// ABSA credit card statements list transactions in five columns: transaction date,
+// posting date, description, reference and amount, with credits suffixed "CR".
+type absaCardParser struct{}
+
+func (absaCardParser) Name() string {
+	return "ABSA Credit Card Statement"
+}
+
+func (absaCardParser) Detect(pages []ExtractionResponse) bool {
+	title := bankStatementTitle(pages)
+
+	return strings.Contains(title, "ABSA") && isAbsaCardStatement(title)
+}
+
+func (absaCardParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	text := statementText(pages)
+	summary := Metadata{}
+	if match := absaDueDate.FindStringSubmatch(text); match != nil {
+		summary["dueDate"] = match[1]
+	}
+	if match := absaMinimumPayment.FindStringSubmatch(text); match != nil {
+		if amount, err := parseAmount(match[1]); err == nil {
+			summary["minimumPayment"] = amount
+		}
+	}
+
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseAbsaCardPage(pages[i], summary, pack))
+	}
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
+
+func isAbsaCardStatement(text string) bool {
+	return strings.Contains(text, "CREDIT CARD STATEMENT") || strings.Contains(text, "CARD ACCOUNT STATEMENT")
+}
+
+func parseAbsaCardPage(response ExtractionResponse, summary Metadata, pack CountryPack) ParsedStatement {
+	var parsed ParsedStatement
+
+	row := -1
+	for _, tables := range response.Tables {
+		for _, table := range tables {
+			row++
+
+			value := strings.ToUpper(strings.TrimSpace(table.Four))
+			credit := strings.HasSuffix(value, "CR")
+			value = strings.TrimSuffix(strings.TrimSuffix(value, "CR"), "DR")
+
+			amount, err := parseAmount(value)
+			if err != nil || amount == 0 {
+				// Column headers, wrapped text and balance brought forward rows.
+				continue
+			}
+			if amount < 0 {
+				credit, amount = true, -amount
+			}
+
+			date, err := parseDate(table.Zero, absaDateLayouts...)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid transaction date %q", table.Zero)
+
+				continue
+			}
+
+			description := normaliseNarration(table.Two)
+			if description == "" {
+				parsed.reject(response.Page, row, "missing description")
+
+				continue
+			}
+			if credit && isCardPayment(description) {
+				// Payments towards the card settle its balance; they are not income.
+				continue
+			}
+
+			meta := Metadata{
+				"reference":       strings.TrimSpace(table.Three),
+				"transactionDate": strings.TrimSpace(table.Zero),
+				"postingDate":     strings.TrimSpace(table.One),
+			}
+			for key, value := range summary {
+				meta[key] = value
+			}
+
+			if credit {
+				// Merchant refunds give back money spent on the card.
+				parsed.addBankTransaction(pack, date, description, 0, amount, CardCredit, meta)
+			} else {
+				parsed.addBankTransaction(pack, date, description, amount, 0, CardCredit, meta)
+			}
+		}
+	}
+
+	return parsed
+}
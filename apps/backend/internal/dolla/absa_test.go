@@ -0,0 +1,64 @@
+package dolla
+
+import "testing"
+
+func TestAbsaParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, absaParser{}, []parserCase{
+		{fixture: "absa.json", golden: "absa.golden"},
+	})
+}
+
+func TestAbsaParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := absaParser{}.Parse(readPages(t, "absa.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	checkRows(t, parsed, map[string]parsedRow{
+		"SALARY CREDIT ACME LTD":        {income: true, amount: 60000, method: BankTransfer},
+		"VISA POS JAVA HOUSE WESTLANDS": {amount: 1250, method: CardDebit},
+		"S/O SCHOOL FEES":               {amount: 15000, method: BankStandingOrd},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 1, Row: 4, Reason: `invalid debit amount "5,0OO.00"`},
+		{Page: 1, Row: 5, Reason: `invalid transaction date "2024.03.08"`},
+	})
+}
+
+func TestAbsaCardParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, absaCardParser{}, []parserCase{
+		{fixture: "absa_card.json", golden: "absa_card.golden"},
+	})
+}
+
+func TestAbsaCardParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := absaCardParser{}.Parse(readPages(t, "absa_card.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Payments towards the card settle its balance, they are not income, while refunds are.
+	checkRows(t, parsed, map[string]parsedRow{
+		"JAVA HOUSE WESTLANDS":        {amount: 1200, method: CardCredit},
+		"NETFLIX.COM":                 {amount: 1100, method: CardCredit},
+		"REFUND JAVA HOUSE WESTLANDS": {income: true, amount: 450, method: CardCredit},
+		"NAIVAS REVERSAL":             {income: true, amount: 300, method: CardCredit},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 1, Row: 7, Reason: `invalid transaction date "32/03/2024"`},
+	})
+
+	for i := range parsed.Expenses {
+		if due := parsed.Expenses[i].Meta["dueDate"]; due != "25/04/2024" {
+			t.Errorf("%s has due date %v, want 25/04/2024", parsed.Expenses[i].Description, due)
+		}
+	}
+}
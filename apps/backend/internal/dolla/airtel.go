@@ -0,0 +1,209 @@
+package dolla
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Airtel Money statements list transactions in seven columns: transaction ID, date,
+// description, status, a signed amount that is negative for debits, fee and balance.
+var airtelDateLayouts = []string{ //nolint:gochecknoglobals
+	"02-01-2006 15:04:05", "02-01-2006 15:04", "02/01/2006 15:04:05", "02/01/2006 15:04", time.DateTime,
+}
+
+// airtelCounterpartyMarkers precede the counterparty in Airtel Money descriptions,
+// longest first so that "SENT MONEY TO" wins over "TO".
+var airtelCounterpartyMarkers = []string{ //nolint:gochecknoglobals
+	"RECEIVED MONEY FROM", "SENT MONEY TO", "RECEIVED FROM", "PAYMENT TO", "PAID TO", "SENT TO", "FROM", "TO", "AT",
+}
+
+type airtelParser struct{}
+
+func (airtelParser) Name() string {
+	return "Airtel Money Statement"
+}
+
+func (airtelParser) Detect(pages []ExtractionResponse) bool {
+	text := statementText(pages)
+	if !strings.Contains(text, "AIRTEL MONEY") {
+		return false
+	}
+
+	return hasHeader(pages, func(table Table) bool {
+		return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(table.Zero)), "TRANSACTION ID")
+	})
+}
+
+func (airtelParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseAirtelPage(pages[i], pack))
+	}
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
+
+func parseAirtelPage(response ExtractionResponse, pack CountryPack) ParsedStatement { //nolint:cyclop
+	var parsed ParsedStatement
+
+	row := -1
+	for _, tables := range response.Tables {
+		for _, table := range tables {
+			row++
+
+			receipt := strings.TrimSpace(table.Zero)
+			if receipt == "" || strings.HasPrefix(strings.ToUpper(receipt), "TRANSACTION") ||
+				strings.TrimSpace(table.Four) == "" {
+				// Headers, blank rows and wrapped text.
+				continue
+			}
+
+			status := strings.ToUpper(strings.TrimSpace(table.Three))
+			if status == "FAILED" || status == "REVERSED" {
+				parsed.reject(response.Page, row, "transaction %s", strings.ToLower(status))
+
+				continue
+			}
+
+			date, err := parseDate(table.One, airtelDateLayouts...)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid transaction date %q", table.One)
+
+				continue
+			}
+
+			description := normaliseNarration(table.Two)
+			if description == "" {
+				parsed.reject(response.Page, row, "missing description")
+
+				continue
+			}
+
+			amount, err := parseAmount(table.Four)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid amount %q", table.Four)
+
+				continue
+			}
+			fee, err := parseAmount(table.Five)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid fee %q", table.Five)
+
+				continue
+			}
+
+			meta := Metadata{
+				"receiptNo":         receipt,
+				"completionTime":    strings.TrimSpace(table.One),
+				"transactionStatus": strings.TrimSpace(table.Three),
+				"balance":           strings.TrimSpace(table.Six),
+			}
+
+			switch {
+			case amount > 0:
+				parsed.Incomes = append(parsed.Incomes, Income{
+					BaseEntity:     BaseEntity{Meta: meta},
+					Date:           Date{date},
+					Source:         extractAirtelCounterparty(description),
+					Category:       pack.Category(description),
+					Description:    description,
+					PaymentMethod:  toAirtelPaymentMethod(description),
+					Amount:         amount,
+					IsRecurring:    isRecurringTransaction(description),
+					OriginalAmount: amount,
+					Status:         Imported,
+				})
+			case amount < 0:
+				parsed.Expenses = append(parsed.Expenses, Expense{
+					BaseEntity:    BaseEntity{Meta: meta},
+					Date:          Date{date},
+					Merchant:      extractAirtelCounterparty(description),
+					Category:      pack.Category(description),
+					Description:   description,
+					PaymentMethod: toAirtelPaymentMethod(description),
+					Amount:        math.Abs(amount),
+					Status:        Imported,
+				})
+			default:
+				parsed.reject(response.Page, row, "no amount")
+
+				continue
+			}
+
+			// Airtel charges its fee on the same row as the transaction. Fees charged on
+			// incoming money are recorded as a separate expense sharing the transaction ID.
+			fee = math.Abs(fee)
+			if fee > 0 && amount < 0 {
+				parsed.Expenses[len(parsed.Expenses)-1].Fee = fee
+			} else if fee > 0 {
+				parsed.Expenses = append(parsed.Expenses, Expense{
+					BaseEntity: BaseEntity{Meta: Metadata{
+						"receiptNo":         receipt,
+						"completionTime":    strings.TrimSpace(table.One),
+						"transactionStatus": strings.TrimSpace(table.Three),
+						"feeFor":            description,
+						"charge":            true,
+					}},
+					Date:          Date{date},
+					Merchant:      "AIRTEL MONEY",
+					Category:      OtherCategory,
+					Description:   "Airtel Money charge",
+					PaymentMethod: AirtelMoney,
+					Amount:        fee,
+					Status:        Imported,
+				})
+			}
+		}
+	}
+
+	return parsed
+}
+
+// extractAirtelCounterparty returns the name of the other party to a transaction, e.g.
+// "JOHN DOE" from "Sent Money to 0733123456 John Doe", falling back to the phone number.
+func extractAirtelCounterparty(description string) string {
+	description = strings.ToUpper(description)
+
+	for _, marker := range airtelCounterpartyMarkers {
+		_, rest, found := strings.Cut(" "+description+" ", " "+marker+" ")
+		if !found {
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		var phone string
+		for len(fields) > 0 && isPhoneNumber(fields[0]) {
+			phone = fields[0]
+			fields = fields[1:]
+		}
+
+		if name := strings.Join(fields, " "); name != "" {
+			return name
+		}
+		if phone != "" {
+			return phone
+		}
+	}
+
+	return description
+}
+
+func isPhoneNumber(value string) bool {
+	value = strings.TrimPrefix(value, "+")
+
+	return len(value) >= 9 && strings.IndexFunc(value, func(r rune) bool {
+		return !unicode.IsDigit(r)
+	}) == -1
+}
+
+func toAirtelPaymentMethod(description string) PaymentMethod {
+	description = strings.ToUpper(description)
+	if strings.Contains(description, "WITHDRAW") || strings.Contains(description, "AGENT") {
+		return Cash
+	}
+
+	return AirtelMoney
+}
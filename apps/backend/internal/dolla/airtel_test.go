@@ -0,0 +1,61 @@
+package dolla
+
+import "testing"
+
+func TestAirtelParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, airtelParser{}, []parserCase{
+		{fixture: "airtel.json", golden: "airtel.golden"},
+	})
+}
+
+func TestAirtelParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := airtelParser{}.Parse(readPages(t, "airtel.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Fees on incoming money are kept as separate charges sharing the transaction ID.
+	checkRows(t, parsed, map[string]parsedRow{
+		"Received Money From 0733123456 John Smith": {income: true, amount: 2000, method: AirtelMoney},
+		"Sent Money To 0733654321 Mary Roe":         {amount: 500, method: AirtelMoney},
+		"Withdraw at Agent 123456 Kamau Shop":       {amount: 1000, method: Cash},
+		"Received From Bank ACME SACCO":             {income: true, amount: 1000, method: AirtelMoney},
+		"Airtel Money charge":                       {amount: 15, method: AirtelMoney},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 1, Row: 4, Reason: "transaction failed"},
+		{Page: 1, Row: 6, Reason: `invalid amount "-2OO.00"`},
+		{Page: 1, Row: 7, Reason: `invalid transaction date "2024-03-07T11:00"`},
+	})
+
+	cases := []struct {
+		description  string
+		counterparty string
+		fee          float64
+	}{
+		{description: "Sent Money To 0733654321 Mary Roe", counterparty: "MARY ROE", fee: 10},
+		{description: "Withdraw at Agent 123456 Kamau Shop", counterparty: "AGENT 123456 KAMAU SHOP"},
+		{description: "Airtel Money charge", counterparty: "AIRTEL MONEY"},
+	}
+	for _, tc := range cases {
+		found := false
+		for i := range parsed.Expenses {
+			expense := parsed.Expenses[i]
+			if expense.Description != tc.description {
+				continue
+			}
+			found = true
+			if expense.Merchant != tc.counterparty || expense.Fee != tc.fee {
+				t.Errorf("%s: got merchant %q and fee %v, want %q and %v",
+					tc.description, expense.Merchant, expense.Fee, tc.counterparty, tc.fee)
+			}
+		}
+		if !found {
+			t.Errorf("%s: not parsed", tc.description)
+		}
+	}
+}
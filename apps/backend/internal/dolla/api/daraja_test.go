@@ -0,0 +1,486 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rodneyosodo/dolla/backend/internal/dolla"
+	"github.com/rodneyosodo/dolla/backend/internal/dolla/repository"
+)
+
+const (
+	testUserID    = "user_daraja"
+	testShortcode = "600100"
+)
+
+// fakeExtractor returns the same pages for every statement, standing in for the pdf-extractor.
+type fakeExtractor struct {
+	pages []dolla.ExtractionResponse
+}
+
+func (e fakeExtractor) Extract(context.Context, io.ReadSeeker, string) ([]dolla.ExtractionResponse, error) {
+	return e.pages, nil
+}
+
+// darajaSender posts callbacks to the server the way Daraja does, to the callback URLs
+// registered for the shortcode.
+type darajaSender struct {
+	url       string
+	shortcode string
+	secret    string
+}
+
+func (d darajaSender) send(t *testing.T, callback string, payload any) (int, dolla.DarajaResponse) {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("failed to encode callback: %s", err)
+	}
+
+	// Daraja posts to the URL as registered, with the secret in its path.
+	url := d.url + "/daraja/" + d.shortcode + "/" + d.secret + "/" + callback
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create callback request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var response dolla.DarajaResponse
+	status := do(t, req, &response)
+
+	return status, response
+}
+
+// newTestServer serves the API over a fresh database, reading every uploaded statement as pages.
+func newTestServer(t *testing.T, pages []dolla.ExtractionResponse) *httptest.Server {
+	t.Helper()
+
+	repo, err := repository.NewRepository(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatalf("failed to create repository: %s", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	svc := dolla.NewService(repo, fakeExtractor{pages: pages}, dolla.DefaultParsers())
+	server := httptest.NewServer(NewHandler(svc, gin.New()))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// registerShortcode registers the test paybill for the test user and returns a sender
+// carrying its secret.
+func registerShortcode(t *testing.T, server *httptest.Server) darajaSender {
+	t.Helper()
+
+	body, err := json.Marshal(dolla.MpesaShortcode{Shortcode: testShortcode, PaymentMethod: dolla.MpesaPaybill})
+	if err != nil {
+		t.Fatalf("failed to encode shortcode: %s", err)
+	}
+	req := newUserRequest(t, http.MethodPost, server.URL+"/mpesa/shortcodes", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	var shortcode dolla.MpesaShortcode
+	if status := do(t, req, &shortcode); status != http.StatusCreated {
+		t.Fatalf("failed to register shortcode: status %d", status)
+	}
+
+	return darajaSender{url: server.URL, shortcode: testShortcode, secret: shortcode.Secret}
+}
+
+func newUserRequest(t *testing.T, method, url string, body io.Reader) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, url, body)
+	if err != nil {
+		t.Fatalf("failed to create request: %s", err)
+	}
+	req.Header.Set("X-User-Id", testUserID)
+
+	return req
+}
+
+// do sends the request and decodes the response body into response, returning the status.
+func do(t *testing.T, req *http.Request, response any) int {
+	t.Helper()
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+		t.Fatalf("failed to decode response: %s", err)
+	}
+
+	return res.StatusCode
+}
+
+func fetchIncomes(t *testing.T, server *httptest.Server) []dolla.Income {
+	t.Helper()
+
+	var page dolla.IncomePage
+	if status := do(t, newUserRequest(t, http.MethodGet, server.URL+"/incomes", nil), &page); status != http.StatusOK {
+		t.Fatalf("failed to list incomes: status %d", status)
+	}
+
+	return page.Incomes
+}
+
+func fetchExpenses(t *testing.T, server *httptest.Server) []dolla.Expense {
+	t.Helper()
+
+	var page dolla.ExpensePage
+	if status := do(t, newUserRequest(t, http.MethodGet, server.URL+"/expenses", nil), &page); status != http.StatusOK {
+		t.Fatalf("failed to list expenses: status %d", status)
+	}
+
+	return page.Expenses
+}
+
+func c2bPayment(receipt, amount string) dolla.DarajaC2BRequest {
+	return dolla.DarajaC2BRequest{
+		TransactionType:   "Pay Bill",
+		TransID:           receipt,
+		TransTime:         "20240302091500",
+		TransAmount:       amount,
+		BusinessShortCode: testShortcode,
+		BillRefNumber:     "INV001",
+		MSISDN:            "254700000001",
+		FirstName:         "JOHN",
+		LastName:          "SMITH",
+	}
+}
+
+func b2cPayment(receipt string, amount float64, code int) dolla.DarajaB2CResult {
+	var result dolla.DarajaB2CResult
+	result.Result.ResultCode = code
+	result.Result.TransactionID = receipt
+	result.Result.ConversationID = "AG_20240305_000001"
+	result.Result.ResultParameters.ResultParameter = []dolla.DarajaParameter{
+		{Key: "TransactionAmount", Value: amount},
+		{Key: "TransactionReceipt", Value: receipt},
+		{Key: "ReceiverPartyPublicName", Value: "254711000222 - MARY ROE"},
+		{Key: "TransactionCompletedDateTime", Value: "05.03.2024 12:30:10"},
+	}
+
+	return result
+}
+
+func TestValidateC2B(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, nil)
+	sender := registerShortcode(t, server)
+
+	otherShortcode := c2bPayment("RCA1B2C3D4", "5000.00")
+	otherShortcode.BusinessShortCode = "600200"
+	noAmount := c2bPayment("RCA1B2C3D4", "0")
+
+	cases := []struct {
+		name       string
+		sender     darajaSender
+		payment    dolla.DarajaC2BRequest
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "accepted",
+			sender:     sender,
+			payment:    c2bPayment("RCA1B2C3D4", "5000.00"),
+			wantStatus: http.StatusOK,
+			wantCode:   "0",
+		},
+		{
+			name:       "wrong secret",
+			sender:     darajaSender{url: server.URL, shortcode: testShortcode, secret: "not-the-secret"},
+			payment:    c2bPayment("RCA1B2C3D4", "5000.00"),
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "C2B00016",
+		},
+		{
+			name:       "unregistered shortcode",
+			sender:     darajaSender{url: server.URL, shortcode: "600200", secret: sender.secret},
+			payment:    otherShortcode,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "C2B00016",
+		},
+		{
+			name:       "payment for another shortcode",
+			sender:     sender,
+			payment:    otherShortcode,
+			wantStatus: http.StatusOK,
+			wantCode:   "C2B00016",
+		},
+		{
+			name:       "no amount",
+			sender:     sender,
+			payment:    noAmount,
+			wantStatus: http.StatusOK,
+			wantCode:   "C2B00016",
+		},
+	}
+
+	// The group returns once its parallel subtests are done, before incomes are counted.
+	t.Run("callbacks", func(t *testing.T) {
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				status, response := tc.sender.send(t, "c2b/validation", tc.payment)
+				if status != tc.wantStatus || response.ResultCode != tc.wantCode {
+					t.Errorf("got status %d and result code %q, want %d and %q",
+						status, response.ResultCode, tc.wantStatus, tc.wantCode)
+				}
+			})
+		}
+	})
+
+	// Validation only accepts or rejects the payment, the confirmation records it.
+	if incomes := fetchIncomes(t, server); len(incomes) != 0 {
+		t.Errorf("validation recorded %d incomes", len(incomes))
+	}
+}
+
+func TestConfirmC2B(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, nil)
+	sender := registerShortcode(t, server)
+	wrongSecret := darajaSender{url: server.URL, shortcode: testShortcode, secret: "not-the-secret"}
+
+	// The callbacks build on each other, so the cases run in order.
+	cases := []struct {
+		name        string
+		sender      darajaSender
+		payment     dolla.DarajaC2BRequest
+		wantStatus  int
+		wantIncomes int
+	}{
+		{
+			name:        "wrong secret",
+			sender:      wrongSecret,
+			payment:     c2bPayment("RCA1B2C3D4", "5000.00"),
+			wantStatus:  http.StatusUnauthorized,
+			wantIncomes: 0,
+		},
+		{
+			name:        "confirmed",
+			sender:      sender,
+			payment:     c2bPayment("RCA1B2C3D4", "5000.00"),
+			wantStatus:  http.StatusOK,
+			wantIncomes: 1,
+		},
+		{
+			name:        "duplicate callback",
+			sender:      sender,
+			payment:     c2bPayment("rca1b2c3d4", "5,000.00"),
+			wantStatus:  http.StatusOK,
+			wantIncomes: 1,
+		},
+		{
+			name:        "missing transaction id",
+			sender:      sender,
+			payment:     c2bPayment("", "700.00"),
+			wantStatus:  http.StatusBadRequest,
+			wantIncomes: 1,
+		},
+		{
+			name:        "another payment",
+			sender:      sender,
+			payment:     c2bPayment("RCK0L1M2N3", "700.00"),
+			wantStatus:  http.StatusOK,
+			wantIncomes: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		status, _ := tc.sender.send(t, "c2b/confirmation", tc.payment)
+		if status != tc.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tc.name, status, tc.wantStatus)
+		}
+		if incomes := fetchIncomes(t, server); len(incomes) != tc.wantIncomes {
+			t.Errorf("%s: got %d incomes, want %d", tc.name, len(incomes), tc.wantIncomes)
+		}
+	}
+
+	incomes := fetchIncomes(t, server)
+	for i := range incomes {
+		if incomes[i].Currency != "KES" || incomes[i].PaymentMethod != dolla.MpesaPaybill {
+			t.Errorf("income %s recorded in %q by %q", incomes[i].Description, incomes[i].Currency,
+				incomes[i].PaymentMethod)
+		}
+	}
+}
+
+func TestRecordB2CResult(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, nil)
+	sender := registerShortcode(t, server)
+	wrongSecret := darajaSender{url: server.URL, shortcode: testShortcode, secret: "not-the-secret"}
+
+	// The callbacks build on each other, so the cases run in order.
+	cases := []struct {
+		name         string
+		sender       darajaSender
+		result       dolla.DarajaB2CResult
+		wantStatus   int
+		wantExpenses int
+	}{
+		{
+			name:         "wrong secret",
+			sender:       wrongSecret,
+			result:       b2cPayment("RCB2C3D4E5", 1000, 0),
+			wantStatus:   http.StatusUnauthorized,
+			wantExpenses: 0,
+		},
+		{
+			name:         "failed payment",
+			sender:       sender,
+			result:       b2cPayment("RCB2C3D4E5", 1000, 2001),
+			wantStatus:   http.StatusOK,
+			wantExpenses: 0,
+		},
+		{
+			name:         "completed payment",
+			sender:       sender,
+			result:       b2cPayment("RCB2C3D4E5", 1000, 0),
+			wantStatus:   http.StatusOK,
+			wantExpenses: 1,
+		},
+		{
+			name:         "duplicate callback",
+			sender:       sender,
+			result:       b2cPayment("RCB2C3D4E5", 1000, 0),
+			wantStatus:   http.StatusOK,
+			wantExpenses: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		status, _ := tc.sender.send(t, "b2c/result", tc.result)
+		if status != tc.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tc.name, status, tc.wantStatus)
+		}
+		if expenses := fetchExpenses(t, server); len(expenses) != tc.wantExpenses {
+			t.Errorf("%s: got %d expenses, want %d", tc.name, len(expenses), tc.wantExpenses)
+		}
+	}
+}
+
+// mpesaStatement is an M-Pesa statement page holding a paybill payment received and a
+// payment sent, under the receipts the Daraja callbacks report.
+func mpesaStatement() []dolla.ExtractionResponse {
+	return []dolla.ExtractionResponse{{
+		Page: 1,
+		Text: "M-PESA STATEMENT\nCustomer Name: JANE DOE",
+		Tables: [][]dolla.Table{{
+			{
+				Zero: "Receipt No.", One: "Completion Time", Two: "Details", Three: "Transaction Status",
+				Four: "Paid in", Five: "Withdrawn", Six: "Balance",
+			},
+			{
+				Zero: "RCB2C3D4E5", One: "2024-03-05 12:30:10", Two: "Business Payment to 254711***222 - MARY ROE",
+				Three: "Completed", Five: "-1,000.00", Six: "4,000.00",
+			},
+			{
+				Zero: "RCA1B2C3D4", One: "2024-03-02 09:15:00", Two: "Pay Bill Online from 254700***001 - JOHN SMITH",
+				Three: "Completed", Four: "5,000.00", Six: "5,000.00",
+			},
+		}},
+	}}
+}
+
+func previewStatement(t *testing.T, server *httptest.Server) dolla.ImportPreview {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "statement.pdf")
+	if err != nil {
+		t.Fatalf("failed to create form: %s", err)
+	}
+	if _, err := part.Write([]byte("%PDF-1.4")); err != nil {
+		t.Fatalf("failed to write form: %s", err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatalf("failed to close form: %s", err)
+	}
+
+	req := newUserRequest(t, http.MethodPost, server.URL+"/transactions/mpesa/preview", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	var preview dolla.ImportPreview
+	if status := do(t, req, &preview); status != http.StatusOK {
+		t.Fatalf("failed to preview statement: status %d", status)
+	}
+
+	return preview
+}
+
+func commitStatement(t *testing.T, server *httptest.Server, token string) dolla.ImportJob {
+	t.Helper()
+
+	body, err := json.Marshal(dolla.ImportCommit{Token: token})
+	if err != nil {
+		t.Fatalf("failed to encode commit: %s", err)
+	}
+	req := newUserRequest(t, http.MethodPost, server.URL+"/transactions/commit", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	var job dolla.ImportJob
+	if status := do(t, req, &job); status != http.StatusOK {
+		t.Fatalf("failed to commit statement: status %d", status)
+	}
+
+	return job
+}
+
+func TestDarajaCallbacksMatchStatementRows(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, mpesaStatement())
+	sender := registerShortcode(t, server)
+
+	// The payment received is confirmed by Daraja before the statement is imported.
+	if status, _ := sender.send(t, "c2b/confirmation", c2bPayment("RCA1B2C3D4", "5000.00")); status != http.StatusOK {
+		t.Fatalf("failed to confirm payment: status %d", status)
+	}
+
+	preview := previewStatement(t, server)
+	if len(preview.Incomes) != 1 || !preview.Incomes[0].Duplicate {
+		t.Fatalf("statement row of the confirmed payment not previewed as a duplicate: %+v", preview.Incomes)
+	}
+	if len(preview.Expenses) != 1 || preview.Expenses[0].Duplicate {
+		t.Fatalf("statement row of the payment sent previewed as a duplicate: %+v", preview.Expenses)
+	}
+
+	job := commitStatement(t, server, preview.Token)
+	if job.Result == nil || job.Result.DuplicatesSkipped != 1 || job.Result.IncomesCreated != 0 ||
+		job.Result.ExpensesCreated != 1 {
+		t.Fatalf("unexpected import result: %+v", job.Result)
+	}
+
+	// The payment sent is reported by Daraja after the statement was imported.
+	if status, _ := sender.send(t, "b2c/result", b2cPayment("RCB2C3D4E5", 1000, 0)); status != http.StatusOK {
+		t.Fatalf("failed to record payment: status %d", status)
+	}
+
+	if incomes := fetchIncomes(t, server); len(incomes) != 1 {
+		t.Errorf("got %d incomes, want 1", len(incomes))
+	}
+	if expenses := fetchExpenses(t, server); len(expenses) != 1 {
+		t.Errorf("got %d expenses, want 1", len(expenses))
+	}
+}
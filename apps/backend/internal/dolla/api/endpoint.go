@@ -1,6 +1,9 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 
@@ -274,9 +277,259 @@ func createTransactions(svc dolla.Service) func(c *gin.Context) {
 			return
 		}
 
+		// The type is empty on POST /transactions, in which case the statement type is detected.
 		statementType := c.Param("type")
+		password := c.PostForm("password")
 
-		if err := svc.CreateTransaction(c.Request.Context(), userID, dolla.Statement(statementType), file); err != nil {
+		job, err := svc.CreateTransaction(c.Request.Context(), userID, dolla.Statement(statementType), file, password)
+		if err != nil {
+			encodeImportError(c, err)
+
+			return
+		}
+
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+func previewTransactions(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		statementType := c.Param("type")
+		password := c.PostForm("password")
+
+		preview, err := svc.PreviewTransaction(
+			c.Request.Context(), userID, dolla.Statement(statementType), file, password,
+		)
+		if err != nil {
+			encodeImportError(c, err)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, preview)
+	}
+}
+
+func commitTransactions(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		var commit dolla.ImportCommit
+		if err := c.ShouldBindJSON(&commit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		job, err := svc.CommitTransaction(c.Request.Context(), userID, commit)
+		switch {
+		case errors.Is(err, dolla.ErrPreviewNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, dolla.ErrInvalidCategoryEdit):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err != nil:
+			encodeImportError(c, err)
+		default:
+			c.JSON(http.StatusOK, job)
+		}
+	}
+}
+
+// importCSV imports a CSV export. The columns are described either by a saved preset,
+// given as mappingId, or by a JSON mapping, which is saved as a preset when saveAs names one.
+func importCSV(svc dolla.Service) func(c *gin.Context) { //nolint:cyclop
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		var mapping dolla.CSVMapping
+		switch id := c.PostForm("mappingId"); id {
+		case "":
+			if err := json.Unmarshal([]byte(c.PostForm("mapping")), &mapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mapping: " + err.Error()})
+
+				return
+			}
+
+			if name := c.PostForm("saveAs"); name != "" {
+				preset := dolla.CSVMappingPreset{UserID: userID, Name: name, Mapping: mapping}
+				if _, err := svc.CreateCSVMapping(c.Request.Context(), preset); err != nil {
+					encodeCSVError(c, err)
+
+					return
+				}
+			}
+		default:
+			preset, err := svc.GetCSVMapping(c.Request.Context(), userID, id)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+
+				return
+			}
+			mapping = preset.Mapping
+		}
+
+		job, err := svc.ImportCSV(c.Request.Context(), userID, file, mapping)
+		if err != nil {
+			encodeCSVError(c, err)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// importSMS imports pasted confirmations, sent as the messages field of a form or JSON body,
+// and the SMS Backup & Restore export uploaded as file.
+func importSMS(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		var req struct {
+			Messages string `json:"messages"`
+		}
+		var backup *multipart.FileHeader
+		if c.ContentType() == gin.MIMEJSON {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+				return
+			}
+		} else {
+			req.Messages = c.PostForm("messages")
+			if file, err := c.FormFile("file"); err == nil {
+				backup = file
+			}
+		}
+
+		job, err := svc.ImportSMS(c.Request.Context(), userID, req.Messages, backup)
+		switch {
+		case errors.Is(err, dolla.ErrNoSMS), errors.Is(err, dolla.ErrInvalidSMSBackup):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusOK, job)
+		}
+	}
+}
+
+func createCSVMapping(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		var preset dolla.CSVMappingPreset
+		if err := c.ShouldBindJSON(&preset); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+		preset.UserID = userID
+
+		preset, err := svc.CreateCSVMapping(c.Request.Context(), preset)
+		if err != nil {
+			encodeCSVError(c, err)
+
+			return
+		}
+
+		c.JSON(http.StatusCreated, preset)
+	}
+}
+
+func getCSVMapping(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		id := c.Param("id")
+		preset, err := svc.GetCSVMapping(c.Request.Context(), userID, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, preset)
+	}
+}
+
+func listCSVMappings(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		presets, err := svc.ListCSVMappings(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"mappings": presets})
+	}
+}
+
+func deleteCSVMapping(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		id := c.Param("id")
+		if err := svc.DeleteCSVMapping(c.Request.Context(), userID, id); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 
 			return
@@ -286,6 +539,342 @@ func createTransactions(svc dolla.Service) func(c *gin.Context) {
 	}
 }
 
+func getFeeReport(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		report, err := svc.GetFeeReport(c.Request.Context(), userID, c.Query("from"), c.Query("to"))
+		switch {
+		case errors.Is(err, dolla.ErrInvalidMonth):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusOK, report)
+		}
+	}
+}
+
+func listBalances(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		balances, err := svc.ListBalances(c.Request.Context(), userID, dolla.Statement(c.Param("id")))
+		switch {
+		case errors.Is(err, dolla.ErrInvalidAccount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusOK, gin.H{"balances": balances})
+		}
+	}
+}
+
+func createShortcode(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		var shortcode dolla.MpesaShortcode
+		if err := c.ShouldBindJSON(&shortcode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+		shortcode.UserID = userID
+
+		shortcode, err := svc.CreateShortcode(c.Request.Context(), shortcode)
+		switch {
+		case errors.Is(err, dolla.ErrInvalidShortcode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, dolla.ErrShortcodeRegistered):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusCreated, shortcode)
+		}
+	}
+}
+
+func listShortcodes(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		shortcodes, err := svc.ListShortcodes(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"shortcodes": shortcodes})
+	}
+}
+
+func deleteShortcode(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		id := c.Param("id")
+		if err := svc.DeleteShortcode(c.Request.Context(), userID, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	}
+}
+
+// validateC2B answers Daraja's C2B validation request. Every answer carries a Daraja result
+// code: payments that cannot be accepted are rejected with 200, while callbacks without the
+// shortcode's secret get 401, unreadable payloads 400 and failures to check them 500.
+func validateC2B(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dolla.DarajaC2BRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dolla.DarajaResponse{ResultCode: "C2B00016", ResultDesc: "Rejected"})
+
+			return
+		}
+
+		err := svc.ValidateC2B(c.Request.Context(), c.Param("shortcode"), c.Param("secret"), req)
+		switch {
+		case errors.Is(err, dolla.ErrInvalidDarajaSecret):
+			c.JSON(http.StatusUnauthorized, dolla.DarajaResponse{ResultCode: "C2B00016", ResultDesc: "Rejected"})
+		case errors.Is(err, dolla.ErrInvalidDarajaCallback):
+			c.JSON(http.StatusOK, dolla.DarajaResponse{ResultCode: "C2B00016", ResultDesc: "Rejected"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, dolla.DarajaResponse{ResultCode: "C2B00016", ResultDesc: "Rejected"})
+		default:
+			c.JSON(http.StatusOK, dolla.DarajaResponse{ResultCode: "0", ResultDesc: "Accepted"})
+		}
+	}
+}
+
+func confirmC2B(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dolla.DarajaC2BRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dolla.DarajaResponse{ResultCode: "1", ResultDesc: err.Error()})
+
+			return
+		}
+
+		err := svc.ConfirmC2B(c.Request.Context(), c.Param("shortcode"), c.Param("secret"), req)
+		encodeDarajaResult(c, err)
+	}
+}
+
+func recordB2CResult(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var result dolla.DarajaB2CResult
+		if err := c.ShouldBindJSON(&result); err != nil {
+			c.JSON(http.StatusBadRequest, dolla.DarajaResponse{ResultCode: "1", ResultDesc: err.Error()})
+
+			return
+		}
+
+		err := svc.RecordB2CResult(c.Request.Context(), c.Param("shortcode"), c.Param("secret"), result)
+		encodeDarajaResult(c, err)
+	}
+}
+
+// encodeDarajaResult acknowledges a Daraja callback, or responds with the status matching
+// the error from recording it.
+func encodeDarajaResult(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, dolla.ErrInvalidDarajaSecret):
+		c.JSON(http.StatusUnauthorized, dolla.DarajaResponse{ResultCode: "1", ResultDesc: err.Error()})
+	case errors.Is(err, dolla.ErrInvalidDarajaCallback):
+		c.JSON(http.StatusBadRequest, dolla.DarajaResponse{ResultCode: "1", ResultDesc: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, dolla.DarajaResponse{ResultCode: "1", ResultDesc: err.Error()})
+	default:
+		c.JSON(http.StatusOK, dolla.DarajaResponse{ResultCode: "0", ResultDesc: "Success"})
+	}
+}
+
+// encodeCSVError responds with the status matching an error from importing a CSV export.
+func encodeCSVError(c *gin.Context, err error) {
+	if errors.Is(err, dolla.ErrInvalidCSVMapping) || errors.Is(err, dolla.ErrInvalidCSV) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// encodeImportError responds with the status matching an error from reading a statement.
+func encodeImportError(c *gin.Context, err error) {
+	if errors.Is(err, dolla.ErrInvalidPassword) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+
+		return
+	}
+
+	var undetected *dolla.UndetectedStatementError
+	if errors.As(err, &undetected) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "candidates": undetected.Candidates})
+
+		return
+	}
+
+	switch {
+	case errors.Is(err, dolla.ErrStatementRejected), errors.Is(err, dolla.ErrUnsupportedStatement):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, dolla.ErrImportQueueFull):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case errors.Is(err, dolla.ErrExtractorTimeout):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+	case errors.Is(err, dolla.ErrExtractorUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// encodeImportJobError responds with the status matching an error from looking up or
+// deleting an import job.
+func encodeImportJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, dolla.ErrImportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, dolla.ErrImportInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func listStatementTypes(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Without a user ID every statement type is listed.
+		c.JSON(http.StatusOK, svc.ListStatementTypes(c.Request.Context(), getUserID(c)))
+	}
+}
+
+func listCountryPacks(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"countries": svc.ListCountryPacks(c.Request.Context())})
+	}
+}
+
+func getImportJob(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		id := c.Param("id")
+		job, err := svc.GetImportJob(c.Request.Context(), userID, id)
+		if err != nil {
+			encodeImportJobError(c, err)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+func getStatementDocument(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		document, err := svc.GetStatementDocument(c.Request.Context(), userID, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, document)
+	}
+}
+
+func deleteImport(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		id := c.Param("id")
+		if err := svc.DeleteImport(c.Request.Context(), userID, id); err != nil {
+			encodeImportJobError(c, err)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	}
+}
+
+func listImportJobs(svc dolla.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID := getUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID required"})
+
+			return
+		}
+
+		query, err := getQueryParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		jobs, err := svc.ListImportJobs(c.Request.Context(), userID, query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, jobs)
+	}
+}
+
 func getUserProfile(svc dolla.Service) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		clerkUserID := c.Param("clerk_user_id")
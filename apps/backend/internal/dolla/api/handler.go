@@ -18,8 +18,37 @@ func NewHandler(svc dolla.Service, router *gin.Engine) *gin.Engine {
 	router.PUT("/expenses/:id", updateExpense(svc))
 	router.DELETE("/expenses/:id", deleteExpense(svc))
 
+	router.GET("/transactions/types", listStatementTypes(svc))
+	router.POST("/transactions", createTransactions(svc))
 	router.POST("/transactions/:type", createTransactions(svc))
+	router.POST("/transactions/:type/preview", previewTransactions(svc))
+	router.POST("/transactions/commit", commitTransactions(svc))
+	router.POST("/transactions/csv", importCSV(svc))
+	router.POST("/transactions/sms", importSMS(svc))
 
+	router.GET("/csv-mappings", listCSVMappings(svc))
+	router.POST("/csv-mappings", createCSVMapping(svc))
+	router.GET("/csv-mappings/:id", getCSVMapping(svc))
+	router.DELETE("/csv-mappings/:id", deleteCSVMapping(svc))
+
+	router.GET("/mpesa/shortcodes", listShortcodes(svc))
+	router.POST("/mpesa/shortcodes", createShortcode(svc))
+	router.DELETE("/mpesa/shortcodes/:id", deleteShortcode(svc))
+
+	// Daraja only registers callback URLs, so they carry the shortcode's secret in their path
+	// instead of a user ID.
+	router.POST("/daraja/:shortcode/:secret/c2b/validation", validateC2B(svc))
+	router.POST("/daraja/:shortcode/:secret/c2b/confirmation", confirmC2B(svc))
+	router.POST("/daraja/:shortcode/:secret/b2c/result", recordB2CResult(svc))
+
+	router.GET("/imports", listImportJobs(svc))
+	router.GET("/imports/:id", getImportJob(svc))
+	router.GET("/imports/:id/document", getStatementDocument(svc))
+	router.DELETE("/imports/:id", deleteImport(svc))
+
+	router.GET("/accounts/:id/balances", listBalances(svc))
+
+	router.GET("/countries", listCountryPacks(svc))
 	router.GET("/profile/:clerk_user_id", getUserProfile(svc))
 	router.POST("/onboarding/:clerk_user_id", completeOnboarding(svc))
 
@@ -31,5 +60,7 @@ func NewHandler(svc dolla.Service, router *gin.Engine) *gin.Engine {
 	router.GET("/budgets/summary", getBudgetSummary(svc))
 	router.POST("/budgets/calculate", calculateBudgetProgress(svc))
 
+	router.GET("/reports/fees", getFeeReport(svc))
+
 	return router
 }
@@ -0,0 +1,87 @@
+package dolla
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrInvalidAccount is returned when balances are asked for an account that is not a
+// supported statement type.
+var ErrInvalidAccount = errors.New("invalid account")
+
+// balanceEpsilon is how far a running balance may be from the expected one, to allow for
+// the rounding of amounts printed in cents.
+const balanceEpsilon = 0.005
+
+// balanceRow is a statement row with a running balance, kept in the order the statement
+// lists it so that the balances can be checked against each other.
+type balanceRow struct {
+	page      uint64
+	row       int
+	receiptNo string
+	date      time.Time
+	// amount is positive for money in and negative for money out.
+	amount  float64
+	balance float64
+}
+
+// checkBalances records the running balance of each row as a snapshot, oldest first, and
+// flags each row whose balance is not the previous balance plus its amount. Statements
+// listing their latest transactions first, as M-Pesa's do, are read bottom up. The opening
+// and closing balances are worked out from the first and last rows.
+func (p *ParsedStatement) checkBalances() {
+	rows := p.balanceRows
+	p.balanceRows = nil
+	if len(rows) == 0 {
+		return
+	}
+
+	if !rows[0].date.Before(rows[len(rows)-1].date) {
+		reversed := make([]balanceRow, len(rows))
+		for i := range rows {
+			reversed[len(rows)-1-i] = rows[i]
+		}
+		rows = reversed
+	}
+
+	// Statements with a running balance rarely state their opening and closing balances.
+	if p.Balances == nil {
+		p.Balances = &StatementBalances{
+			Opening: math.Round((rows[0].balance-rows[0].amount)*100) / 100,
+			Closing: rows[len(rows)-1].balance,
+		}
+	}
+
+	p.Snapshots = make([]BalanceSnapshot, len(rows))
+	for i, row := range rows {
+		p.Snapshots[i] = BalanceSnapshot{
+			Date:      row.date,
+			Balance:   row.balance,
+			ReceiptNo: row.receiptNo,
+		}
+
+		if i == 0 {
+			continue
+		}
+		expected := rows[i-1].balance + row.amount
+		if math.Abs(expected-row.balance) > balanceEpsilon {
+			p.BalanceGaps = append(p.BalanceGaps, BalanceGap{
+				Page:      row.page,
+				Row:       row.row,
+				ReceiptNo: row.receiptNo,
+				Expected:  math.Round(expected*100) / 100,
+				Balance:   row.balance,
+			})
+		}
+	}
+}
+
+func (s *service) ListBalances(ctx context.Context, userID string, account Statement) ([]BalanceSnapshot, error) {
+	if !s.parsers.Supports(account) {
+		return nil, ErrInvalidAccount
+	}
+
+	return s.repo.ListBalanceSnapshots(ctx, userID, account)
+}
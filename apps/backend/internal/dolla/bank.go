@@ -0,0 +1,237 @@
+package dolla
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseAmount parses a statement amount such as "1,250.00" or "KES 1,250.00".
+func parseAmount(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "KES")
+	value = strings.TrimPrefix(value, "KSH")
+	value = strings.TrimPrefix(value, "UGX")
+	value = strings.TrimPrefix(value, "TZS")
+	value = strings.ReplaceAll(value, ",", "")
+	value = strings.ReplaceAll(value, " ", "")
+
+	if value == "" || value == "-" {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseDate parses a statement date using the first layout that matches.
+func parseDate(value string, layouts ...string) (time.Time, error) {
+	value = strings.TrimSpace(strings.ReplaceAll(value, "\n", " "))
+
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, errors.New("unrecognised date: " + value)
+}
+
+// normaliseNarration joins a multi-line bank narration into a single line.
+func normaliseNarration(narration string) string {
+	return strings.Join(strings.Fields(narration), " ")
+}
+
+// hasValueDateHeader reports whether the statement has a value date column. Bank statements
+// have one, while mobile money statements, which may mention a bank in their narrations, do not.
+func hasValueDateHeader(pages []ExtractionResponse) bool {
+	return hasHeader(pages, func(table Table) bool {
+		return strings.EqualFold(normaliseNarration(table.One), "Value Date")
+	})
+}
+
+// bankTitleLines bounds the title area of a first page whose text has no column header row.
+const bankTitleLines = 20
+
+// bankStatementTitle returns the upper-cased title area of the first page: the lines above
+// the column header row, where banks print their name and address. Narrations, which often
+// name other banks, come below it and are left out.
+func bankStatementTitle(pages []ExtractionResponse) string {
+	if len(pages) == 0 {
+		return ""
+	}
+
+	lines := strings.Split(strings.ToUpper(pages[0].Text), "\n")
+	for i := range lines {
+		if i == bankTitleLines || strings.Contains(lines[i], "VALUE DATE") {
+			lines = lines[:i]
+
+			break
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func toBankPaymentMethod(description string) PaymentMethod {
+	description = strings.ToUpper(description)
+
+	switch {
+	case strings.Contains(description, "STANDING ORDER"), strings.HasPrefix(description, "STO "),
+		strings.HasPrefix(description, "S/O "):
+		return BankStandingOrd
+	case strings.Contains(description, "CHEQUE"), strings.HasPrefix(description, "CHQ "):
+		return Cheque
+	case strings.Contains(description, "PESALINK"):
+		return Pesalink
+	case strings.Contains(description, "MPESA"), strings.Contains(description, "M-PESA"):
+		return MpesaPaybill
+	case strings.Contains(description, "POS "), strings.Contains(description, "VISA"),
+		strings.Contains(description, "MASTERCARD"), strings.Contains(description, "CARD"):
+		return CardDebit
+	case strings.Contains(description, "ATM"), strings.Contains(description, "CASH"):
+		return Cash
+	default:
+		return BankTransfer
+	}
+}
+
+// isCardPayment reports whether a credit on a card statement is a payment towards the card,
+// which settles its balance, rather than a refund.
+func isCardPayment(description string) bool {
+	return containsAny(strings.ToUpper(description), []string{
+		"PAYMENT RECEIVED", "PAYMENT - THANK YOU", "PAYMENT THANK YOU", "DIRECT DEBIT PAYMENT",
+	})
+}
+
+// bankLayout describes a statement in the seven column layout most banks share: transaction
+// date, value date, narration, reference, debit, credit and balance. Banks differ in how
+// they write dates, what they call the columns and how their narrations name payment methods.
+type bankLayout struct {
+	dates []string
+	// valueDateFallback dates rows that leave the transaction date blank by their value date.
+	valueDateFallback bool
+	// narration, debit and credit name the columns in the reasons rows are rejected for.
+	narration string
+	debit     string
+	credit    string
+	method    func(description string) PaymentMethod
+	// chequeNumber, when set, reports whether a row's reference is a cheque number, which
+	// makes the row a cheque whatever its narration says.
+	chequeNumber func(reference string) bool
+}
+
+// newBankLayout returns the layout of a bank that only differs in how it writes dates.
+func newBankLayout(dates []string) bankLayout {
+	return bankLayout{
+		dates:     dates,
+		narration: "narration",
+		debit:     "debit",
+		credit:    "credit",
+		method:    toBankPaymentMethod,
+	}
+}
+
+// parseBankPage reads a page of a statement in the shared seven column layout.
+func parseBankPage(response ExtractionResponse, layout bankLayout, pack CountryPack) ParsedStatement {
+	var parsed ParsedStatement
+
+	row := -1
+	for _, tables := range response.Tables {
+		for _, table := range tables {
+			row++
+
+			debit, debitErr := parseAmount(table.Four)
+			credit, creditErr := parseAmount(table.Five)
+			if debitErr != nil && creditErr != nil {
+				// Column headers and wrapped text carry no amounts.
+				continue
+			}
+			if debitErr == nil && creditErr == nil && debit == 0 && credit == 0 {
+				// Opening and closing balance rows.
+				continue
+			}
+
+			transactionDate := strings.TrimSpace(table.Zero)
+			if transactionDate == "" && layout.valueDateFallback {
+				transactionDate = strings.TrimSpace(table.One)
+			}
+			date, err := parseDate(transactionDate, layout.dates...)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid transaction date %q", table.Zero)
+
+				continue
+			}
+
+			description := normaliseNarration(table.Two)
+			if description == "" {
+				parsed.reject(response.Page, row, "missing %s", layout.narration)
+
+				continue
+			}
+
+			switch {
+			case debitErr != nil:
+				parsed.reject(response.Page, row, "invalid %s amount %q", layout.debit, table.Four)
+
+				continue
+			case creditErr != nil:
+				parsed.reject(response.Page, row, "invalid %s amount %q", layout.credit, table.Five)
+
+				continue
+			}
+
+			reference := strings.TrimSpace(table.Three)
+			meta := Metadata{
+				"reference":       reference,
+				"transactionDate": strings.TrimSpace(table.Zero),
+				"valueDate":       strings.TrimSpace(table.One),
+				"balance":         strings.TrimSpace(table.Six),
+			}
+
+			method := layout.method(description)
+			if layout.chequeNumber != nil && layout.chequeNumber(reference) {
+				meta["chequeNo"] = reference
+				method = Cheque
+			}
+
+			parsed.addBankTransaction(pack, date, description, debit, credit, method, meta)
+		}
+	}
+
+	return parsed
+}
+
+// addBankTransaction records a bank statement row as an income when money came in
+// and as an expense otherwise, categorised with the country pack.
+func (p *ParsedStatement) addBankTransaction(
+	pack CountryPack, date time.Time, description string, debit, credit float64, method PaymentMethod, meta Metadata,
+) {
+	if credit > 0 {
+		p.Incomes = append(p.Incomes, Income{
+			BaseEntity:     BaseEntity{Meta: meta},
+			Date:           Date{date},
+			Source:         extractSource(description),
+			Category:       pack.Category(description),
+			Description:    description,
+			PaymentMethod:  method,
+			Amount:         credit,
+			IsRecurring:    isRecurringTransaction(description),
+			OriginalAmount: credit,
+			Status:         Imported,
+		})
+
+		return
+	}
+
+	p.Expenses = append(p.Expenses, Expense{
+		BaseEntity:    BaseEntity{Meta: meta},
+		Date:          Date{date},
+		Merchant:      extractMerchant(description),
+		Category:      pack.Category(description),
+		Description:   description,
+		PaymentMethod: method,
+		Amount:        debit,
+		Status:        Imported,
+	})
+}
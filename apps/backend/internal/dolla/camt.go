@@ -0,0 +1,250 @@
+package dolla
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// camt053Parser reads ISO 20022 camt.053 bank to customer statements. Elements are
+// matched on their local names, so every version of the message is understood.
+type camt053Parser struct{}
+
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID       string        `xml:"Id"`
+	IBAN     string        `xml:"Acct>Id>IBAN"`
+	Other    string        `xml:"Acct>Id>Othr>Id"`
+	Currency string        `xml:"Acct>Ccy"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtBalance struct {
+	Type   string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount camtAmount `xml:"Amt"`
+	Sign   string     `xml:"CdtDbtInd"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtDate holds either a date or a date and time, as camt.053 allows both.
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtEntry struct {
+	Reference         string            `xml:"NtryRef"`
+	Amount            camtAmount        `xml:"Amt"`
+	Sign              string            `xml:"CdtDbtInd"`
+	Reversal          bool              `xml:"RvslInd"`
+	Status            camtStatus        `xml:"Sts"`
+	BookingDate       camtDate          `xml:"BookgDt"`
+	ValueDate         camtDate          `xml:"ValDt"`
+	ServicerReference string            `xml:"AcctSvcrRef"`
+	Transactions      []camtTransaction `xml:"NtryDtls>TxDtls"`
+	AdditionalInfo    string            `xml:"AddtlNtryInf"`
+}
+
+// camtStatus holds the entry status, which is a code element from version 8 on
+// and plain text before.
+type camtStatus struct {
+	Code  string `xml:"Cd"`
+	Value string `xml:",chardata"`
+}
+
+type camtTransaction struct {
+	EndToEndID   string   `xml:"Refs>EndToEndId"`
+	Creditor     string   `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPty  string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Debtor       string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPty    string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Unstructured []string `xml:"RmtInf>Ustrd"`
+}
+
+func (camt053Parser) Name() string {
+	return "camt.053 XML File"
+}
+
+func (camt053Parser) Detect(file []byte) bool {
+	head := file[:min(len(file), 1<<10)]
+
+	return bytes.Contains(head, []byte("camt.053")) || bytes.Contains(head, []byte("BkToCstmrStmt"))
+}
+
+func (camt053Parser) Parse(file []byte, pack CountryPack) (ParsedStatement, error) {
+	var document camtDocument
+	if err := xml.Unmarshal(file, &document); err != nil {
+		return ParsedStatement{}, fmt.Errorf("invalid camt.053 file: %w", err)
+	}
+
+	var parsed ParsedStatement
+	row := 0
+	for _, statement := range document.Statements {
+		account := statement.IBAN
+		if account == "" {
+			account = statement.Other
+		}
+
+		for _, entry := range statement.Entries {
+			parseCAMTEntry(&parsed, row, entry, account, statement.Currency, pack)
+			row++
+		}
+	}
+
+	// Balances only describe a single account, so they are left out for files holding several.
+	if len(document.Statements) == 1 {
+		parsed.Balances = document.Statements[0].balances()
+	}
+
+	return parsed, nil
+}
+
+func parseCAMTEntry( //nolint:cyclop
+	parsed *ParsedStatement, row int, entry camtEntry, account, currency string, pack CountryPack,
+) {
+	if firstNonEmpty(entry.Status.Code, entry.Status.Value) != "BOOK" {
+		// Pending and informational entries may still change, so only booked ones are imported.
+		return
+	}
+
+	date, err := entry.BookingDate.parse()
+	if err != nil {
+		parsed.reject(0, row, "invalid booking date %q", entry.BookingDate.Date+entry.BookingDate.DateTime)
+
+		return
+	}
+
+	amount, err := parseAmount(entry.Amount.Value)
+	if err != nil || amount <= 0 {
+		parsed.reject(0, row, "invalid amount %q", entry.Amount.Value)
+
+		return
+	}
+
+	// A reversed debit returns money to the account and a reversed credit takes it away.
+	credit := (entry.Sign == "CRDT") != entry.Reversal
+
+	var details camtTransaction
+	if len(entry.Transactions) > 0 {
+		details = entry.Transactions[0]
+	}
+	counterparty := firstNonEmpty(details.Creditor, details.CreditorPty)
+	if credit {
+		counterparty = firstNonEmpty(details.Debtor, details.DebtorPty)
+	}
+
+	description := normaliseNarration(strings.Join(
+		[]string{counterparty, strings.Join(details.Unstructured, " "), entry.AdditionalInfo}, " ",
+	))
+	if description == "" {
+		parsed.reject(0, row, "missing entry details")
+
+		return
+	}
+
+	if entry.Amount.Currency != "" {
+		currency = entry.Amount.Currency
+	}
+
+	meta := Metadata{
+		"reference":          firstNonEmpty(entry.ServicerReference, entry.Reference, details.EndToEndID),
+		"entryRef":           entry.Reference,
+		"accountServicerRef": entry.ServicerReference,
+		"endToEndId":         details.EndToEndID,
+		"accountId":          account,
+		"valueDate":          firstNonEmpty(entry.ValueDate.Date, entry.ValueDate.DateTime),
+		"currency":           currency,
+	}
+
+	var debit, creditAmount float64
+	if credit {
+		creditAmount = amount
+	} else {
+		debit = amount
+	}
+
+	parsed.addBankTransaction(pack, date, description, debit, creditAmount, toBankPaymentMethod(description), meta)
+	if credit {
+		parsed.Incomes[len(parsed.Incomes)-1].Currency = currency
+	}
+}
+
+// balances returns the opening and closing booked balances of the statement, or nil
+// when it does not report both.
+func (s camtStatement) balances() *StatementBalances {
+	var opening, closing *camtBalance
+	for i := range s.Balances {
+		switch s.Balances[i].Type {
+		case "OPBD", "PRCD":
+			if opening == nil {
+				opening = &s.Balances[i]
+			}
+		case "CLBD":
+			closing = &s.Balances[i]
+		}
+	}
+	if opening == nil || closing == nil {
+		return nil
+	}
+
+	openingAmount, err := opening.amount()
+	if err != nil {
+		return nil
+	}
+	closingAmount, err := closing.amount()
+	if err != nil {
+		return nil
+	}
+
+	return &StatementBalances{
+		Opening:  openingAmount,
+		Closing:  closingAmount,
+		Currency: firstNonEmpty(closing.Amount.Currency, s.Currency),
+	}
+}
+
+func (b camtBalance) amount() (float64, error) {
+	amount, err := parseAmount(b.Amount.Value)
+	if err != nil {
+		return 0, err
+	}
+	if b.Sign == "DBIT" {
+		amount = -amount
+	}
+
+	return amount, nil
+}
+
+func (d camtDate) parse() (time.Time, error) {
+	if d.Date != "" {
+		return time.Parse(time.DateOnly, strings.TrimSpace(d.Date))
+	}
+
+	// Only the date of a date and time is kept, as printed in the statement's time zone.
+	value := strings.TrimSpace(d.DateTime)
+	if len(value) < len(time.DateOnly) {
+		return time.Time{}, fmt.Errorf("unrecognised date: %s", value)
+	}
+
+	return time.Parse(time.DateOnly, value[:len(time.DateOnly)])
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,40 @@
+package dolla
+
+import "testing"
+
+func TestCAMTParse(t *testing.T) {
+	t.Parallel()
+
+	checkFileParser(t, camt053Parser{}, []parserCase{
+		{fixture: "camt053.xml", golden: "camt053.golden"},
+	})
+}
+
+func TestCAMTParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := camt053Parser{}.Parse(readFixture(t, "camt053.xml"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Only booked entries are read, and a reversed card payment returns money to the account.
+	checkRows(t, parsed, map[string]parsedRow{
+		"ACME GMBH SALARY MARCH 2024":               {income: true, amount: 2000, method: BankTransfer},
+		"CITY POWER AG ELECTRICITY INVOICE 2024-03": {amount: 150, method: BankTransfer},
+		"RETURN OF CARD PAYMENT":                    {income: true, amount: 20, method: CardDebit},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 0, Row: 4, Reason: `invalid booking date "31.03.2024"`},
+	})
+
+	want := StatementBalances{Opening: 1000, Closing: 2830, Currency: "EUR"}
+	if parsed.Balances == nil || *parsed.Balances != want {
+		t.Errorf("got balances %+v, want %+v", parsed.Balances, want)
+	}
+	for i := range parsed.Incomes {
+		if parsed.Incomes[i].Currency != "EUR" {
+			t.Errorf("%s has currency %q, want EUR", parsed.Incomes[i].Description, parsed.Incomes[i].Currency)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package dolla
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Co-operative Bank statements list transactions in seven columns: transaction date,
+// value date, narrative, cheque number or reference, debit, credit and running balance.
+var coopDateLayouts = []string{ //nolint:gochecknoglobals
+	"02-01-2006", "02/01/2006", "02-Jan-2006", "02 Jan 2006", time.DateOnly,
+}
+
+// Cheque numbers are the six digits printed on the cheque, unlike other references.
+var coopChequeNumber = regexp.MustCompile(`^\d{6}$`) //nolint:gochecknoglobals
+
+// coopLayout marks rows whose reference is a cheque number as cheques.
+var coopLayout = bankLayout{ //nolint:gochecknoglobals
+	dates:        coopDateLayouts,
+	narration:    "narrative",
+	debit:        "debit",
+	credit:       "credit",
+	method:       toBankPaymentMethod,
+	chequeNumber: coopChequeNumber.MatchString,
+}
+
+type coopParser struct{}
+
+func (coopParser) Name() string {
+	return "Co-operative Bank Statement"
+}
+
+func (coopParser) Detect(pages []ExtractionResponse) bool {
+	title := bankStatementTitle(pages)
+
+	return (strings.Contains(title, "CO-OPERATIVE BANK") || strings.Contains(title, "CO-OPBANK.CO.KE")) &&
+		hasValueDateHeader(pages)
+}
+
+func (coopParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseBankPage(pages[i], coopLayout, pack))
+	}
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
@@ -0,0 +1,31 @@
+package dolla
+
+import "testing"
+
+func TestCoopParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, coopParser{}, []parserCase{
+		{fixture: "coop.json", golden: "coop.golden"},
+	})
+}
+
+func TestCoopParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := coopParser{}.Parse(readPages(t, "coop.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Rows carrying a six digit cheque number are cheques, whatever their narrative says.
+	checkRows(t, parsed, map[string]parsedRow{
+		"CHEQUE DEPOSIT JOHN SMITH": {income: true, amount: 12000, method: Cheque},
+		"MPESA B2C TO 0711***222":   {amount: 2500, method: MpesaPaybill},
+		"ATM WITHDRAWAL KIMATHI ST": {amount: 10000, method: Cash},
+		"S/O SACCO CONTRIBUTION":    {amount: 5000, method: BankStandingOrd},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 1, Row: 6, Reason: `invalid credit amount "1,2a0.00"`},
+	})
+}
@@ -0,0 +1,241 @@
+package dolla
+
+import (
+	"sort"
+	"strings"
+)
+
+type Country string
+
+const (
+	Kenya    Country = "KE"
+	Uganda   Country = "UG"
+	Tanzania Country = "TZ"
+)
+
+// CountryPack bundles what importing the statements of a country needs: the statement types
+// issued there, the keywords of local merchants and payment services, and the currency used
+// when a statement does not state one.
+type CountryPack struct {
+	Country    Country     `json:"country"`
+	Name       string      `json:"name"`
+	Currency   string      `json:"currency"`
+	Statements []Statement `json:"statements"`
+
+	categories []categoryKeywords
+	methods    []methodKeywords
+	// method is the payment method of descriptions matching no keywords.
+	method PaymentMethod
+}
+
+// categoryKeywords assigns the category to descriptions containing any of the keywords.
+type categoryKeywords struct {
+	category Category
+	keywords []string
+}
+
+// methodKeywords assigns the payment method to descriptions containing any of the keywords.
+type methodKeywords struct {
+	method   PaymentMethod
+	keywords []string
+}
+
+// commonCategories are the keywords shared by every country, checked in order.
+// Country packs add the names of local merchants and utilities to them.
+var commonCategories = []categoryKeywords{ //nolint:gochecknoglobals
+	// Income related.
+	{SalaryWages, []string{"SALARY", "WAGES"}},
+	{FreelanceGigWork, []string{
+		"BUSINESS PAYMENT", "FREELANCE", "COMMISSION", "CONSULTING", "TRANSFER FROM BANK", "LOAN DISBURSEMENT",
+	}},
+	{GiftsRemittances, []string{"FUNDS RECEIVED", "GIFT", "FAMILY", "RELATIVE"}},
+	{RentalIncome, []string{"RENT", "RENTAL"}},
+	{Interest, []string{"INTEREST", "DIVIDEND"}},
+	{FarmProduceSales, []string{"FARM", "PRODUCE", "HARVEST"}},
+	{BusinessSalesDaily, []string{"SALE", "SHOP", "CUSTOMER"}},
+	// Expense related.
+	{Utilities, []string{"ELECTRICITY", "WATER"}},
+	{Groceries, []string{"SUPERMARKET", "GROCERY"}},
+	{Transport, []string{"UBER", "BOLT", "BUS", "FUEL", "PETROL"}},
+	{AirtimeData, []string{"AIRTIME", "DATA"}},
+	{FoodDiningOut, []string{"RESTAURANT", "HOTEL", "CAFE", "KFC", "PIZZA"}},
+	{Health, []string{"HOSPITAL", "CLINIC", "PHARMACY", "DOCTOR"}},
+	{Education, []string{"SCHOOL", "UNIVERSITY", "COLLEGE", "TUITION"}},
+	{Entertainment, []string{"CINEMA", "MOVIE", "NETFLIX", "SHOWMAX"}},
+	{Clothing, []string{"CLOTHING", "FASHION", "SHOES"}},
+	{TitheOfferings, []string{"TITHE", "OFFERING", "CHURCH", "DONATION"}},
+	{LoanRepayment, []string{"LOAN", "CREDIT", "REPAYMENT"}},
+	{PersonalCare, []string{"SALON", "BARBER", "SPA"}},
+	{SavingsInvestment, []string{"SAVINGS", "INVESTMENT", "SACCO"}},
+}
+
+var countryPacks = map[Country]CountryPack{ //nolint:gochecknoglobals
+	Kenya: {
+		Country:  Kenya,
+		Name:     "Kenya",
+		Currency: "KES",
+		Statements: []Statement{
+			MpesaStatement, IMBankStatement, EquityStatement, KCBStatement, NCBAStatement,
+			CoopStatement, AbsaStatement, AbsaCardStatement, AirtelStatement,
+		},
+		categories: localCategories(map[Category][]string{
+			Utilities:   {"KPLC", "NAIROBI WATER"},
+			Groceries:   {"NAIVAS", "TUSKYS", "CARREFOUR", "QUICKMART"},
+			Transport:   {"MATATU"},
+			AirtimeData: {"SAFARICOM", "AIRTEL"},
+		}),
+		methods: []methodKeywords{
+			{BankTransfer, []string{"TRANSFER FROM BANK", "BANK TRANSFER"}},
+			{MpesaPaybill, []string{"BUSINESS PAYMENT", "PAYBILL", "PAY BILL"}},
+			{MpesaTill, []string{"TILL", "BUY GOODS"}},
+			{MpesaSendMoney, []string{"SEND MONEY", "SENT TO", "RECEIVED FROM"}},
+			{Cash, []string{"CASH", "WITHDRAW", "AGENT"}},
+			{AirtelMoney, []string{"AIRTEL MONEY"}},
+			{EquitelMoney, []string{"EQUITEL"}},
+			{Tkash, []string{"T-KASH"}},
+		},
+		method: MpesaOnline,
+	},
+	Uganda: {
+		Country:    Uganda,
+		Name:       "Uganda",
+		Currency:   "UGX",
+		Statements: []Statement{MTNUgandaStatement},
+		categories: localCategories(map[Category][]string{
+			Utilities:     {"UMEME", "YAKA", "NWSC", "NATIONAL WATER"},
+			Groceries:     {"SHOPRITE", "CARREFOUR", "CAPITAL SHOPPERS", "QUALITY SUPERMARKET", "MAGIC SUPERMARKET"},
+			Transport:     {"SAFEBODA", "BODA", "TAXI"},
+			AirtimeData:   {"BUNDLE", "INTERNET"},
+			Entertainment: {"DSTV", "GOTV", "STARTIMES"},
+			Education:     {"SCHOOLPAY", "SCHOOL PAY"},
+		}),
+		methods: []methodKeywords{
+			{BankTransfer, []string{"BANK"}},
+			{Cash, []string{"CASH", "WITHDRAW", "AGENT", "DEPOSIT"}},
+			{AirtelMoney, []string{"AIRTEL MONEY"}},
+		},
+		method: MTNMoMo,
+	},
+	Tanzania: {
+		Country:    Tanzania,
+		Name:       "Tanzania",
+		Currency:   "TZS",
+		Statements: []Statement{MpesaTanzaniaStatement},
+		categories: localCategories(map[Category][]string{
+			Utilities:         {"TANESCO", "LUKU", "DAWASA", "DAWASCO"},
+			Groceries:         {"SHOPPERS", "VILLAGE SUPERMARKET", "GAME STORES"},
+			Transport:         {"BAJAJI", "DALADALA", "UDART"},
+			AirtimeData:       {"VODACOM", "TIGO", "HALOTEL", "BUNDLE", "VIFURUSHI", "MUDA WA MAONGEZI"},
+			Entertainment:     {"DSTV", "AZAM", "STARTIMES"},
+			Education:         {"ADA YA SHULE"},
+			TitheOfferings:    {"SADAKA", "ZAKA"},
+			LoanRepayment:     {"SONGESHA", "MKOPO"},
+			SavingsInvestment: {"M-PAWA", "UTT AMIS"},
+		}),
+		methods: []methodKeywords{
+			{BankTransfer, []string{"BANK"}},
+			{MpesaPaybill, []string{"PAY BILL", "LIPA BILI", "BILL PAYMENT", "GEPG"}},
+			{MpesaTill, []string{"LIPA KWA M-PESA", "LIPA NA M-PESA", "BUY GOODS", "TILL"}},
+			{MpesaSendMoney, []string{"SEND MONEY", "TRANSFER TO", "RECEIVED FROM", "TUMA PESA"}},
+			{Cash, []string{"CASH", "WITHDRAW", "AGENT", "WAKALA"}},
+			{TigoPesa, []string{"TIGO PESA", "MIXX BY YAS"}},
+			{AirtelMoney, []string{"AIRTEL MONEY"}},
+			{HaloPesa, []string{"HALOPESA"}},
+		},
+		method: MpesaOnline,
+	},
+}
+
+// localCategories returns the common categories with the country's keywords added.
+func localCategories(local map[Category][]string) []categoryKeywords {
+	categories := make([]categoryKeywords, len(commonCategories))
+	for i, common := range commonCategories {
+		categories[i] = categoryKeywords{
+			category: common.category,
+			keywords: append(append([]string{}, common.keywords...), local[common.category]...),
+		}
+	}
+
+	return categories
+}
+
+// PackFor returns the pack of the country, falling back to Kenya, where dolla started,
+// for users who have not chosen a supported country.
+func PackFor(country Country) CountryPack {
+	if pack, ok := countryPacks[country]; ok {
+		return pack
+	}
+
+	return countryPacks[Kenya]
+}
+
+// CountryPacks lists the supported countries sorted by name.
+func CountryPacks() []CountryPack {
+	packs := make([]CountryPack, 0, len(countryPacks))
+	for _, pack := range countryPacks {
+		packs = append(packs, pack)
+	}
+	sort.Slice(packs, func(i, j int) bool {
+		return packs[i].Name < packs[j].Name
+	})
+
+	return packs
+}
+
+// Category returns the category of the first keywords the description contains.
+func (p CountryPack) Category(description string) Category {
+	description = strings.ToUpper(description)
+	for _, entry := range p.categories {
+		if containsAny(description, entry.keywords) {
+			return entry.category
+		}
+	}
+
+	return OtherCategory
+}
+
+// PaymentMethod returns the payment method of the first keywords the description contains.
+func (p CountryPack) PaymentMethod(description string) PaymentMethod {
+	description = strings.ToUpper(description)
+	for _, entry := range p.methods {
+		if containsAny(description, entry.keywords) {
+			return entry.method
+		}
+	}
+
+	return p.method
+}
+
+// Offers reports whether the statement type is available to users of the country. Types
+// issued in no particular country, such as OFX or CSV exports, are available everywhere.
+func (p CountryPack) Offers(ttype Statement) bool {
+	for _, pack := range countryPacks {
+		for _, statement := range pack.Statements {
+			if statement == ttype {
+				return pack.Country == p.Country
+			}
+		}
+	}
+
+	return true
+}
+
+// localise fills in the currency of incomes from files that belong to no country and do not
+// state one, such as CSV or QIF exports, with the currency of the user's country.
+func (p CountryPack) localise(parsed *ParsedStatement) {
+	for i := range parsed.Incomes {
+		if parsed.Incomes[i].Currency == "" {
+			parsed.Incomes[i].Currency = p.Currency
+		}
+	}
+}
+
+func containsAny(value string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(value, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
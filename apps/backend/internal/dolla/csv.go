@@ -0,0 +1,272 @@
+package dolla
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCSVMapping is returned when a CSV mapping is incomplete or names columns
+	// the file does not have.
+	ErrInvalidCSVMapping = errors.New("invalid csv mapping")
+	// ErrInvalidCSV is returned when a file cannot be read as CSV.
+	ErrInvalidCSV = errors.New("invalid csv file")
+)
+
+// csvColumns are the positions of the mapped columns in a CSV file, -1 when unmapped.
+type csvColumns struct {
+	date, amount, debit, credit, description, reference, currency int
+}
+
+// Validate checks that the mapping names the columns an import needs.
+func (m CSVMapping) Validate() error {
+	switch {
+	case m.DateColumn == "":
+		return fmt.Errorf("%w: missing date column", ErrInvalidCSVMapping)
+	case m.DescriptionColumn == "":
+		return fmt.Errorf("%w: missing description column", ErrInvalidCSVMapping)
+	case m.AmountColumn == "" && m.DebitColumn == "" && m.CreditColumn == "":
+		return fmt.Errorf("%w: missing amount or debit and credit columns", ErrInvalidCSVMapping)
+	case m.AmountColumn != "" && (m.DebitColumn != "" || m.CreditColumn != ""):
+		return fmt.Errorf("%w: use either an amount column or debit and credit columns", ErrInvalidCSVMapping)
+	}
+
+	return nil
+}
+
+func (s *service) ImportCSV(
+	ctx context.Context, userID string, file *multipart.FileHeader, mapping CSVMapping,
+) (ImportJob, error) {
+	if err := mapping.Validate(); err != nil {
+		return ImportJob{}, err
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return ImportJob{}, err
+	}
+	defer f.Close()
+
+	parsed, err := parseCSV(f, mapping, s.countryPack(ctx, userID))
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	job := ImportJob{
+		UserID:    userID,
+		Statement: CSVStatement,
+		FileName:  file.Filename,
+		Status:    ImportSaving,
+	}
+	job.PopulateDataOnCreate(ctx)
+	s.queued.Store(job.ID, struct{}{})
+	defer s.queued.Delete(job.ID)
+	if err := s.repo.CreateImportJob(ctx, job); err != nil {
+		return ImportJob{}, err
+	}
+
+	result, err := s.importTransactions(ctx, userID, job.ID, parsed)
+	if err != nil {
+		s.failImport(ctx, &job, err)
+
+		return ImportJob{}, err
+	}
+
+	job.Result = &result
+	s.setImportStatus(ctx, &job, ImportDone)
+
+	return job, nil
+}
+
+func (s *service) CreateCSVMapping(ctx context.Context, preset CSVMappingPreset) (CSVMappingPreset, error) {
+	if strings.TrimSpace(preset.Name) == "" {
+		return CSVMappingPreset{}, fmt.Errorf("%w: missing name", ErrInvalidCSVMapping)
+	}
+	if err := preset.Mapping.Validate(); err != nil {
+		return CSVMappingPreset{}, err
+	}
+
+	preset.Name = strings.TrimSpace(preset.Name)
+	preset.PopulateDataOnCreate(ctx)
+	if err := s.repo.CreateCSVMapping(ctx, preset); err != nil {
+		return CSVMappingPreset{}, err
+	}
+
+	return preset, nil
+}
+
+func (s *service) GetCSVMapping(ctx context.Context, userID, id string) (CSVMappingPreset, error) {
+	return s.repo.GetCSVMapping(ctx, userID, id)
+}
+
+func (s *service) ListCSVMappings(ctx context.Context, userID string) ([]CSVMappingPreset, error) {
+	return s.repo.ListCSVMappings(ctx, userID)
+}
+
+func (s *service) DeleteCSVMapping(ctx context.Context, userID, id string) error {
+	return s.repo.DeleteCSVMapping(ctx, userID, id)
+}
+
+// parseCSV reads the transactions of a CSV export with a header row. Row numbers in
+// rejected rows count the header as row 0.
+func parseCSV(r io.Reader, mapping CSVMapping, pack CountryPack) (ParsedStatement, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return ParsedStatement{}, fmt.Errorf("%w: header: %w", ErrInvalidCSV, err)
+	}
+
+	columns, err := mapping.columns(header)
+	if err != nil {
+		return ParsedStatement{}, err
+	}
+
+	layout := mapping.DateFormat
+	if layout == "" {
+		layout = time.DateOnly
+	}
+
+	var parsed ParsedStatement
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ParsedStatement{}, fmt.Errorf("%w: row %d: %w", ErrInvalidCSV, row, err)
+		}
+
+		parseCSVRecord(&parsed, row, record, columns, layout, strings.ToUpper(mapping.Currency), pack)
+	}
+
+	return parsed, nil
+}
+
+func parseCSVRecord( //nolint:cyclop
+	parsed *ParsedStatement, row int, record []string, columns csvColumns, layout, currency string, pack CountryPack,
+) {
+	field := func(column int) string {
+		if column < 0 || column >= len(record) {
+			return ""
+		}
+
+		return strings.TrimSpace(record[column])
+	}
+
+	if strings.TrimSpace(strings.Join(record, "")) == "" {
+		return
+	}
+
+	date, err := time.Parse(layout, field(columns.date))
+	if err != nil {
+		parsed.reject(0, row, "invalid date %q", field(columns.date))
+
+		return
+	}
+
+	description := normaliseNarration(field(columns.description))
+	if description == "" {
+		parsed.reject(0, row, "missing description")
+
+		return
+	}
+
+	var debit, credit float64
+	if columns.amount >= 0 {
+		amount, err := parseAmount(field(columns.amount))
+		if err != nil {
+			parsed.reject(0, row, "invalid amount %q", field(columns.amount))
+
+			return
+		}
+		if amount < 0 {
+			debit = math.Abs(amount)
+		} else {
+			credit = amount
+		}
+	} else {
+		if debit, err = parseAmount(field(columns.debit)); err != nil {
+			parsed.reject(0, row, "invalid debit amount %q", field(columns.debit))
+
+			return
+		}
+		if credit, err = parseAmount(field(columns.credit)); err != nil {
+			parsed.reject(0, row, "invalid credit amount %q", field(columns.credit))
+
+			return
+		}
+		debit = math.Abs(debit)
+	}
+	if debit == 0 && credit == 0 {
+		parsed.reject(0, row, "no amount")
+
+		return
+	}
+
+	if value := field(columns.currency); value != "" {
+		currency = strings.ToUpper(value)
+	}
+
+	meta := Metadata{}
+	if currency != "" {
+		meta["currency"] = currency
+	}
+	if reference := field(columns.reference); reference != "" {
+		meta["reference"] = reference
+	}
+
+	parsed.addBankTransaction(pack, date, description, debit, credit, toBankPaymentMethod(description), meta)
+	if credit > 0 {
+		parsed.Incomes[len(parsed.Incomes)-1].Currency = currency
+	}
+}
+
+// columns finds the mapped columns in the CSV header.
+func (m CSVMapping) columns(header []string) (csvColumns, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheet exports may start with a byte order mark.
+		name = strings.TrimPrefix(name, "\ufeff")
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var missing []string
+	find := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			missing = append(missing, name)
+
+			return -1
+		}
+
+		return i
+	}
+
+	columns := csvColumns{
+		date:        find(m.DateColumn),
+		amount:      find(m.AmountColumn),
+		debit:       find(m.DebitColumn),
+		credit:      find(m.CreditColumn),
+		description: find(m.DescriptionColumn),
+		reference:   find(m.ReferenceColumn),
+		currency:    find(m.CurrencyColumn),
+	}
+	if len(missing) > 0 {
+		return csvColumns{}, fmt.Errorf("%w: columns not found: %s", ErrInvalidCSVMapping, strings.Join(missing, ", "))
+	}
+
+	return columns, nil
+}
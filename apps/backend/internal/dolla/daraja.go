@@ -0,0 +1,309 @@
+package dolla
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// darajaTimeLayout is the layout of the TransTime field of C2B callbacks.
+const darajaTimeLayout = "20060102150405"
+
+// darajaB2CTimeLayout is the layout of the completion time reported in B2C results.
+const darajaB2CTimeLayout = "02.01.2006 15:04:05"
+
+var (
+	// ErrInvalidShortcode is returned when a shortcode registration is not a till or paybill number.
+	ErrInvalidShortcode = errors.New("invalid m-pesa shortcode")
+	// ErrShortcodeRegistered is returned when the shortcode already belongs to a user.
+	ErrShortcodeRegistered = errors.New("m-pesa shortcode already registered")
+	// ErrInvalidDarajaSecret is returned when a callback is for an unknown shortcode or
+	// does not carry the shortcode's secret.
+	ErrInvalidDarajaSecret = errors.New("invalid daraja callback secret")
+	// ErrInvalidDarajaCallback is returned when a callback payload cannot be recorded.
+	ErrInvalidDarajaCallback = errors.New("invalid daraja callback")
+)
+
+// DarajaC2BRequest is the payload Daraja posts to the C2B validation and confirmation URLs.
+type DarajaC2BRequest struct {
+	TransactionType   string `json:"TransactionType"`
+	TransID           string `json:"TransID"`
+	TransTime         string `json:"TransTime"`
+	TransAmount       string `json:"TransAmount"`
+	BusinessShortCode string `json:"BusinessShortCode"`
+	BillRefNumber     string `json:"BillRefNumber"`
+	InvoiceNumber     string `json:"InvoiceNumber"`
+	OrgAccountBalance string `json:"OrgAccountBalance"`
+	ThirdPartyTransID string `json:"ThirdPartyTransID"`
+	MSISDN            string `json:"MSISDN"`
+	FirstName         string `json:"FirstName"`
+	MiddleName        string `json:"MiddleName"`
+	LastName          string `json:"LastName"`
+}
+
+// DarajaB2CResult is the payload Daraja posts to the result URL of a B2C payment.
+type DarajaB2CResult struct {
+	Result struct {
+		ResultType               int    `json:"ResultType"`
+		ResultCode               int    `json:"ResultCode"`
+		ResultDesc               string `json:"ResultDesc"`
+		OriginatorConversationID string `json:"OriginatorConversationID"`
+		ConversationID           string `json:"ConversationID"`
+		TransactionID            string `json:"TransactionID"`
+		ResultParameters         struct {
+			ResultParameter []DarajaParameter `json:"ResultParameter"`
+		} `json:"ResultParameters"`
+	} `json:"Result"`
+}
+
+// DarajaParameter is a key and value pair of a B2C result, where the value is a string or a number.
+type DarajaParameter struct {
+	Key   string `json:"Key"`
+	Value any    `json:"Value"`
+}
+
+// DarajaResponse acknowledges a callback. A ResultCode of "0" accepts a C2B validation,
+// anything else rejects the payment.
+type DarajaResponse struct {
+	ResultCode string `json:"ResultCode"`
+	ResultDesc string `json:"ResultDesc"`
+}
+
+func (s *service) CreateShortcode(ctx context.Context, shortcode MpesaShortcode) (MpesaShortcode, error) {
+	shortcode.Shortcode = strings.TrimSpace(shortcode.Shortcode)
+	if !isDigits(shortcode.Shortcode) {
+		return MpesaShortcode{}, fmt.Errorf("%w: %q is not a number", ErrInvalidShortcode, shortcode.Shortcode)
+	}
+	if shortcode.PaymentMethod != MpesaTill && shortcode.PaymentMethod != MpesaPaybill {
+		return MpesaShortcode{}, fmt.Errorf(
+			"%w: payment method must be %q or %q", ErrInvalidShortcode, MpesaTill, MpesaPaybill,
+		)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return MpesaShortcode{}, err
+	}
+	shortcode.Secret = hex.EncodeToString(secret)
+	shortcode.SecretHash = hashDarajaSecret(shortcode.Secret)
+	shortcode.PopulateDataOnCreate(ctx)
+
+	if err := s.repo.CreateShortcode(ctx, shortcode); err != nil {
+		return MpesaShortcode{}, err
+	}
+
+	return shortcode, nil
+}
+
+func (s *service) ListShortcodes(ctx context.Context, userID string) ([]MpesaShortcode, error) {
+	return s.repo.ListShortcodes(ctx, userID)
+}
+
+func (s *service) DeleteShortcode(ctx context.Context, userID, id string) error {
+	return s.repo.DeleteShortcode(ctx, userID, id)
+}
+
+func (s *service) ValidateC2B(ctx context.Context, shortcode, secret string, req DarajaC2BRequest) error {
+	if _, err := s.verifyDaraja(ctx, shortcode, secret); err != nil {
+		return err
+	}
+	if req.BusinessShortCode != shortcode {
+		return fmt.Errorf("%w: payment is for shortcode %s", ErrInvalidDarajaCallback, req.BusinessShortCode)
+	}
+	if amount, err := parseAmount(req.TransAmount); err != nil || amount <= 0 {
+		return fmt.Errorf("%w: invalid amount %q", ErrInvalidDarajaCallback, req.TransAmount)
+	}
+
+	return nil
+}
+
+func (s *service) ConfirmC2B(ctx context.Context, shortcode, secret string, req DarajaC2BRequest) error {
+	registered, err := s.verifyDaraja(ctx, shortcode, secret)
+	if err != nil {
+		return err
+	}
+	if req.BusinessShortCode != shortcode {
+		return fmt.Errorf("%w: payment is for shortcode %s", ErrInvalidDarajaCallback, req.BusinessShortCode)
+	}
+
+	income, err := parseC2BConfirmation(req, registered.PaymentMethod)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.importTransactions(ctx, registered.UserID, "", ParsedStatement{Incomes: []Income{income}})
+
+	return err
+}
+
+func (s *service) RecordB2CResult(ctx context.Context, shortcode, secret string, result DarajaB2CResult) error {
+	registered, err := s.verifyDaraja(ctx, shortcode, secret)
+	if err != nil {
+		return err
+	}
+
+	// Failed payments move no money, Daraja only reports why they failed.
+	if result.Result.ResultCode != 0 {
+		return nil
+	}
+
+	expense, err := parseB2CResult(result, registered.PaymentMethod)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.importTransactions(ctx, registered.UserID, "", ParsedStatement{Expenses: []Expense{expense}})
+
+	return err
+}
+
+// verifyDaraja returns the registered shortcode if the callback carries its secret.
+// Unknown shortcodes fail the same way as wrong secrets so that callers cannot probe
+// which shortcodes are registered.
+func (s *service) verifyDaraja(ctx context.Context, shortcode, secret string) (MpesaShortcode, error) {
+	registered, err := s.repo.GetShortcode(ctx, shortcode)
+	if err != nil {
+		return MpesaShortcode{}, ErrInvalidDarajaSecret
+	}
+	if !hmac.Equal([]byte(hashDarajaSecret(secret)), []byte(registered.SecretHash)) {
+		return MpesaShortcode{}, ErrInvalidDarajaSecret
+	}
+
+	return registered, nil
+}
+
+func hashDarajaSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// parseC2BConfirmation turns a confirmed till or paybill payment into an income. Daraja retries
+// confirmations it has no acknowledgement for, so the receipt number is kept for deduplication.
+func parseC2BConfirmation(req DarajaC2BRequest, method PaymentMethod) (Income, error) {
+	if strings.TrimSpace(req.TransID) == "" {
+		return Income{}, fmt.Errorf("%w: missing transaction id", ErrInvalidDarajaCallback)
+	}
+	amount, err := parseAmount(req.TransAmount)
+	if err != nil || amount <= 0 {
+		return Income{}, fmt.Errorf("%w: invalid amount %q", ErrInvalidDarajaCallback, req.TransAmount)
+	}
+	date, err := time.Parse(darajaTimeLayout, req.TransTime)
+	if err != nil {
+		return Income{}, fmt.Errorf("%w: invalid transaction time %q", ErrInvalidDarajaCallback, req.TransTime)
+	}
+
+	var names []string
+	for _, name := range []string{req.FirstName, req.MiddleName, req.LastName} {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	payer := normaliseNarration(strings.Join(names, " "))
+	if payer == "" {
+		payer = "M-PESA CUSTOMER"
+	}
+
+	description := "Payment from " + payer
+	if method == MpesaPaybill && req.BillRefNumber != "" {
+		description += " for account " + req.BillRefNumber
+	}
+
+	meta := Metadata{
+		"receiptNo":       strings.ToUpper(req.TransID),
+		"completionTime":  date.Format(time.DateTime),
+		"counterparty":    payer,
+		"shortcode":       req.BusinessShortCode,
+		"transactionType": req.TransactionType,
+		"source":          "daraja",
+	}
+	if req.BillRefNumber != "" {
+		meta["account"] = req.BillRefNumber
+	}
+	if req.MSISDN != "" {
+		meta["msisdn"] = req.MSISDN
+	}
+	if req.OrgAccountBalance != "" {
+		if balance, err := parseAmount(req.OrgAccountBalance); err == nil {
+			meta["balance"] = balance
+		}
+	}
+
+	return Income{
+		BaseEntity:     BaseEntity{Meta: meta},
+		Date:           Date{date},
+		Source:         payer,
+		Category:       BusinessSalesDaily,
+		Description:    description,
+		PaymentMethod:  method,
+		Amount:         amount,
+		OriginalAmount: amount,
+		Currency:       PackFor(Kenya).Currency,
+		Status:         Imported,
+	}, nil
+}
+
+// parseB2CResult turns a completed B2C payment out of the shortcode into an expense.
+func parseB2CResult(result DarajaB2CResult, method PaymentMethod) (Expense, error) {
+	params := make(map[string]string)
+	for _, param := range result.Result.ResultParameters.ResultParameter {
+		switch value := param.Value.(type) {
+		case float64:
+			params[param.Key] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			params[param.Key] = strings.TrimSpace(fmt.Sprint(value))
+		}
+	}
+
+	receipt := params["TransactionReceipt"]
+	if receipt == "" {
+		receipt = result.Result.TransactionID
+	}
+	if receipt == "" {
+		return Expense{}, fmt.Errorf("%w: missing transaction receipt", ErrInvalidDarajaCallback)
+	}
+	amount, err := parseAmount(params["TransactionAmount"])
+	if err != nil || amount <= 0 {
+		return Expense{}, fmt.Errorf("%w: invalid amount %q", ErrInvalidDarajaCallback, params["TransactionAmount"])
+	}
+	date, err := time.Parse(darajaB2CTimeLayout, params["TransactionCompletedDateTime"])
+	if err != nil {
+		return Expense{}, fmt.Errorf(
+			"%w: invalid completion time %q", ErrInvalidDarajaCallback, params["TransactionCompletedDateTime"],
+		)
+	}
+
+	// The receiver is reported as "254722000000 - JOHN DOE".
+	receiver := params["ReceiverPartyPublicName"]
+	if _, name, ok := strings.Cut(receiver, " - "); ok {
+		receiver = name
+	}
+	receiver = normaliseNarration(receiver)
+	if receiver == "" {
+		receiver = "M-PESA CUSTOMER"
+	}
+
+	return Expense{
+		BaseEntity: BaseEntity{Meta: Metadata{
+			"receiptNo":      strings.ToUpper(receipt),
+			"completionTime": date.Format(time.DateTime),
+			"counterparty":   receiver,
+			"conversationId": result.Result.ConversationID,
+			"source":         "daraja",
+		}},
+		Date:          Date{date},
+		Merchant:      receiver,
+		Category:      OtherCategory,
+		Description:   "Payment to " + receiver,
+		PaymentMethod: method,
+		Amount:        amount,
+		Status:        Imported,
+	}, nil
+}
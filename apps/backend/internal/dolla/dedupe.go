@@ -0,0 +1,52 @@
+package dolla
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// transactionReference returns the issuer assigned reference stored in the metadata,
+// such as an M-Pesa receipt number or a bank reference.
+func transactionReference(meta Metadata) string {
+	for _, key := range []string{"receiptNo", "reference"} {
+		if ref, ok := meta[key].(string); ok && strings.TrimSpace(ref) != "" {
+			return strings.TrimSpace(ref)
+		}
+	}
+
+	return ""
+}
+
+// dedupeKey identifies an imported transaction so that re-importing an overlapping
+// statement does not insert it twice. Mobile money transactions are keyed on their receipt
+// number and amount, and on whether they are a charge, since a charge on incoming money
+// shares the receipt number of the transfer. The key leaves the description out so that
+// the copies of a receipt read from a statement, an SMS and a Daraja callback match.
+// OFX transactions are keyed on their account and FITID, which the issuer keeps unique
+// and stable across downloads. Other transactions with a reference are keyed on it
+// together with the description. Otherwise the date, amount and description are used.
+func dedupeKey(meta Metadata, date Date, amount float64, description string) string {
+	hash := sha256.New()
+
+	fitid, _ := meta["fitid"].(string)
+	receipt, _ := meta["receiptNo"].(string)
+	switch {
+	case strings.TrimSpace(fitid) != "":
+		account, _ := meta["accountId"].(string)
+		fmt.Fprintf(hash, "fitid|%s|%s", account, strings.TrimSpace(fitid))
+	case strings.TrimSpace(receipt) != "":
+		role := "primary"
+		if charge, _ := meta["charge"].(bool); charge {
+			role = "charge"
+		}
+		fmt.Fprintf(hash, "receipt|%s|%s|%.2f", strings.ToUpper(strings.TrimSpace(receipt)), role, amount)
+	case transactionReference(meta) != "":
+		fmt.Fprintf(hash, "ref|%s|%s", transactionReference(meta), description)
+	default:
+		fmt.Fprintf(hash, "row|%s|%.2f|%s", date, amount, description)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
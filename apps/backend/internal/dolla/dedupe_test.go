@@ -0,0 +1,178 @@
+package dolla
+
+import (
+	"testing"
+	"time"
+)
+
+// dedupeRow holds what dedupeKey reads from a transaction.
+type dedupeRow struct {
+	meta        Metadata
+	date        Date
+	amount      float64
+	description string
+}
+
+func (r dedupeRow) key() string {
+	return dedupeKey(r.meta, r.date, r.amount, r.description)
+}
+
+func TestDedupeKeyGolden(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := mpesaParser{}.Parse(readPages(t, "mpesa.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	stampTransactions("user", parsed.Incomes, parsed.Expenses)
+
+	keys := make(map[string]string)
+	for i := range parsed.Incomes {
+		keys["income "+parsed.Incomes[i].Description] = parsed.Incomes[i].DedupeKey
+	}
+	for i := range parsed.Expenses {
+		keys["expense "+parsed.Expenses[i].Description] = parsed.Expenses[i].DedupeKey
+	}
+
+	checkGolden(t, "mpesa_dedupe.golden", keys)
+}
+
+func TestDedupeKey(t *testing.T) {
+	t.Parallel()
+
+	date := Date{time.Date(2024, 3, 5, 12, 30, 10, 0, time.UTC)}
+	payment := dedupeRow{
+		meta:        Metadata{"receiptNo": "RCB2C3D4E5"},
+		date:        date,
+		amount:      1000,
+		description: "Pay Bill to 888880 - KPLC PREPAID",
+	}
+	transfer := dedupeRow{
+		meta:        Metadata{"reference": "FT24065ABC"},
+		date:        date,
+		amount:      5000,
+		description: "RTGS FROM ACME LTD",
+	}
+	deposit := dedupeRow{date: date, amount: 750, description: "CASH DEPOSIT"}
+	download := dedupeRow{
+		meta:        Metadata{"fitid": "2024030501", "accountId": "0123456789"},
+		date:        date,
+		amount:      250,
+		description: "POS PURCHASE",
+	}
+
+	cases := []struct {
+		name  string
+		first dedupeRow
+		again dedupeRow
+		same  bool
+	}{
+		{
+			name:  "receipt imported again",
+			first: payment,
+			again: payment,
+			same:  true,
+		},
+		{
+			name:  "another receipt",
+			first: payment,
+			again: dedupeRow{
+				meta:        Metadata{"receiptNo": "RCB2C3D4E6"},
+				date:        date,
+				amount:      1000,
+				description: "Pay Bill to 888880 - KPLC PREPAID",
+			},
+		},
+		{
+			// SMS and Daraja copies of a receipt carry their own dates and descriptions.
+			name:  "receipt read from an sms",
+			first: payment,
+			again: dedupeRow{
+				meta:        Metadata{"receiptNo": "rcb2c3d4e5", "source": "sms"},
+				date:        Date{date.AddDate(0, 0, 1)},
+				amount:      1000,
+				description: "Pay Bill to KPLC PREPAID account 12345678901",
+			},
+			same: true,
+		},
+		{
+			name:  "charge on the receipt",
+			first: payment,
+			again: dedupeRow{
+				meta:        Metadata{"receiptNo": "RCB2C3D4E5", "charge": true},
+				date:        date,
+				amount:      1000,
+				description: "Pay Bill to 888880 - KPLC PREPAID",
+			},
+		},
+		{
+			name:  "another amount on the receipt",
+			first: payment,
+			again: dedupeRow{
+				meta:        Metadata{"receiptNo": "RCB2C3D4E5"},
+				date:        date,
+				amount:      15,
+				description: "Pay Bill to 888880 - KPLC PREPAID",
+			},
+		},
+		{
+			name:  "reference imported again",
+			first: transfer,
+			again: dedupeRow{
+				meta:        Metadata{"reference": " FT24065ABC "},
+				date:        date,
+				amount:      5000,
+				description: "RTGS FROM ACME LTD",
+			},
+			same: true,
+		},
+		{
+			name:  "another transaction on the reference",
+			first: transfer,
+			again: dedupeRow{meta: Metadata{"reference": "FT24065ABC"}, date: date, amount: 50, description: "RTGS CHARGES"},
+		},
+		{
+			name:  "row without a reference imported again",
+			first: deposit,
+			again: deposit,
+			same:  true,
+		},
+		{
+			name:  "same row on another day",
+			first: deposit,
+			again: dedupeRow{date: Date{date.AddDate(0, 0, 1)}, amount: 750, description: "CASH DEPOSIT"},
+		},
+		{
+			// Banks rewrite descriptions and posting dates between downloads, but not the FITID.
+			name:  "ofx downloaded again",
+			first: download,
+			again: dedupeRow{
+				meta:        Metadata{"fitid": "2024030501", "accountId": "0123456789"},
+				date:        Date{date.AddDate(0, 0, 1)},
+				amount:      250,
+				description: "POS",
+			},
+			same: true,
+		},
+		{
+			name:  "fitid of another account",
+			first: download,
+			again: dedupeRow{
+				meta:        Metadata{"fitid": "2024030501", "accountId": "9876543210"},
+				date:        date,
+				amount:      250,
+				description: "POS PURCHASE",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if same := tc.first.key() == tc.again.key(); same != tc.same {
+				t.Errorf("keys match: %t, want %t", same, tc.same)
+			}
+		})
+	}
+}
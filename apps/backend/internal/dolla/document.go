@@ -0,0 +1,127 @@
+package dolla
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// checkHeader compares the totals and period the statement header states with its
+// transactions, warning of each disagreement. Rows the parser rejected or the extractor
+// missed make the totals disagree.
+func (p *ParsedStatement) checkHeader() {
+	if p.Header == nil {
+		return
+	}
+
+	if p.Header.TotalIn != nil {
+		var total float64
+		for i := range p.Incomes {
+			total += p.Incomes[i].Amount
+		}
+		if math.Abs(total-*p.Header.TotalIn) > balanceEpsilon {
+			p.Warnings = append(p.Warnings, fmt.Sprintf(
+				"statement total paid in is %.2f but its transactions add up to %.2f", *p.Header.TotalIn, total,
+			))
+		}
+	}
+
+	if p.Header.TotalOut != nil {
+		// Charges are paid out too, though they are recorded as the fees of their expenses.
+		var total float64
+		for i := range p.Expenses {
+			total += p.Expenses[i].Amount + p.Expenses[i].Fee
+		}
+		if math.Abs(total-*p.Header.TotalOut) > balanceEpsilon {
+			p.Warnings = append(p.Warnings, fmt.Sprintf(
+				"statement total paid out is %.2f but its transactions add up to %.2f", *p.Header.TotalOut, total,
+			))
+		}
+	}
+
+	if period := p.Header.Period; period != nil {
+		// Transactions carry the time they were made, so the period runs to the end of its last day.
+		end := period.To.AddDate(0, 0, 1)
+		var outside int
+		for i := range p.Incomes {
+			if p.Incomes[i].Date.Before(period.From.Time) || !p.Incomes[i].Date.Before(end) {
+				outside++
+			}
+		}
+		for i := range p.Expenses {
+			if p.Expenses[i].Date.Before(period.From.Time) || !p.Expenses[i].Date.Before(end) {
+				outside++
+			}
+		}
+		if outside > 0 {
+			p.Warnings = append(p.Warnings, fmt.Sprintf(
+				"transactions outside the statement period %s to %s: %d", period.From, period.To, outside,
+			))
+		}
+	}
+}
+
+// recordDocument stores what the uploaded statement says about itself. It is logged rather
+// than returned when it fails, since the statement's transactions are already imported.
+func (s *service) recordDocument(ctx context.Context, job *ImportJob, fileHash string, parsed ParsedStatement) {
+	document := StatementDocument{
+		UserID:    job.UserID,
+		ImportID:  job.ID,
+		Statement: job.Statement,
+		Parser:    s.parserName(job.Statement),
+		FileName:  job.FileName,
+		FileHash:  fileHash,
+		Warnings:  parsed.Warnings,
+	}
+	if document.Warnings == nil {
+		document.Warnings = Warnings{}
+	}
+
+	period := parsed.period()
+	if header := parsed.Header; header != nil {
+		document.HolderName = header.HolderName
+		document.MobileNumber = header.MobileNumber
+		document.TotalIn = header.TotalIn
+		document.TotalOut = header.TotalOut
+		if header.Period != nil {
+			period = header.Period
+		}
+	}
+	if period != nil {
+		document.PeriodFrom, document.PeriodTo = &period.From, &period.To
+	}
+	if balances := parsed.Balances; balances != nil {
+		document.OpeningBalance, document.ClosingBalance = &balances.Opening, &balances.Closing
+	}
+
+	document.PopulateDataOnCreate(ctx)
+	if err := s.repo.CreateStatementDocument(ctx, document); err != nil {
+		slog.Error("failed to record statement document", slog.String("id", job.ID), slog.String("err", err.Error()))
+	}
+}
+
+// hashFile returns the hex SHA-256 of the uploaded statement.
+func hashFile(file []byte) string {
+	hash := sha256.Sum256(file)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// parserName returns the name of the parser handling the statement type.
+func (s *service) parserName(ttype Statement) string {
+	if parser, ok := s.parsers.Get(ttype); ok {
+		return parser.Name()
+	}
+	if parser, ok := s.parsers.GetFile(ttype); ok {
+		return parser.Name()
+	}
+
+	return ""
+}
+
+func (s *service) GetStatementDocument(ctx context.Context, userID, importID string) (StatementDocument, error) {
+	return s.repo.GetStatementDocument(ctx, userID, importID)
+}
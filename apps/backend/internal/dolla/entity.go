@@ -5,6 +5,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,10 +14,34 @@ import (
 type Statement string
 
 const (
-	MpesaStatement  Statement = "mpesa"
-	IMBankStatement Statement = "imbank"
+	MpesaStatement    Statement = "mpesa"
+	IMBankStatement   Statement = "imbank"
+	EquityStatement   Statement = "equity"
+	KCBStatement      Statement = "kcb"
+	NCBAStatement     Statement = "ncba"
+	CoopStatement     Statement = "coop"
+	AbsaStatement     Statement = "absa"
+	AbsaCardStatement Statement = "absa-card"
+	AirtelStatement   Statement = "airtel"
+	OFXStatement      Statement = "ofx"
+	QIFStatement      Statement = "qif"
+	CAMT053Statement  Statement = "camt053"
+	MT940Statement    Statement = "mt940"
+	// MTNUgandaStatement and MpesaTanzaniaStatement are offered to users in Uganda and Tanzania.
+	MTNUgandaStatement     Statement = "mtn-ug"
+	MpesaTanzaniaStatement Statement = "mpesa-tz"
+	// CSVStatement marks imports of CSV exports, which are read with a column mapping
+	// instead of a statement parser.
+	CSVStatement Statement = "csv"
+	// SMSStatement marks imports of M-Pesa and Airtel Money SMS confirmations.
+	SMSStatement Statement = "sms"
 )
 
+type StatementType struct {
+	Type Statement `json:"type"`
+	Name string    `json:"name"`
+}
+
 type PaymentMethod string
 
 const (
@@ -34,6 +59,9 @@ const (
 	AirtelMoney     PaymentMethod = "airtel money"
 	EquitelMoney    PaymentMethod = "equitel money"
 	Tkash           PaymentMethod = "t-kash"
+	MTNMoMo         PaymentMethod = "mtn momo"
+	TigoPesa        PaymentMethod = "tigo pesa"
+	HaloPesa        PaymentMethod = "halopesa"
 	Paypal          PaymentMethod = "paypal"
 	GooglePay       PaymentMethod = "google pay"
 	ApplePay        PaymentMethod = "apple pay"
@@ -76,6 +104,20 @@ const (
 	OtherCategory Category = "other"
 )
 
+// categories lists every category above, for checking the categories users choose.
+var categories = []Category{ //nolint:gochecknoglobals
+	SalaryWages, FreelanceGigWork, BusinessSalesDaily, RentalIncome, Dividends, Interest, FarmProduceSales,
+	ConsultingFees, Commissions, GrantsBursaries, LoanRepaymentReceived, GiftsRemittances,
+	Groceries, Utilities, RentHousing, Transport, AirtimeData, FoodDiningOut, LoanRepayment, Clothing,
+	Education, Health, Entertainment, PersonalCare, SavingsInvestment, TitheOfferings, RemittancesSent,
+	OtherCategory,
+}
+
+// known reports whether the category is one of the categories above.
+func (c Category) known() bool {
+	return slices.Contains(categories, c)
+}
+
 type Status string
 
 const (
@@ -182,7 +224,11 @@ type Expense struct {
 	Description   string        `db:"description"    json:"description"`
 	PaymentMethod PaymentMethod `db:"payment_method" json:"paymentMethod"`
 	Amount        float64       `db:"amount"         json:"amount"`
-	Status        Status        `db:"status"         json:"status"`
+	// Fee is the transaction cost charged on top of the amount, such as an M-Pesa charge.
+	Fee       float64 `db:"fee"        json:"fee"`
+	Status    Status  `db:"status"     json:"status"`
+	DedupeKey string  `db:"dedupe_key" json:"-"`
+	ImportID  string  `db:"import_id"  json:"importId,omitempty"`
 }
 
 type Income struct {
@@ -199,6 +245,340 @@ type Income struct {
 	IsRecurring    bool          `db:"is_recurring"    json:"isRecurring"`
 	OriginalAmount float64       `db:"original_amount" json:"originalAmount"`
 	Status         Status        `db:"status"          json:"status"`
+	DedupeKey      string        `db:"dedupe_key"      json:"-"`
+	ImportID       string        `db:"import_id"       json:"importId,omitempty"`
+}
+
+// RejectedRow is a statement row that could not be turned into a transaction.
+type RejectedRow struct {
+	Page   uint64 `json:"page"`
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+// StatementBalances are the balances a statement reports at the start and end of its period,
+// negative when the account is overdrawn.
+type StatementBalances struct {
+	Opening  float64 `json:"opening"`
+	Closing  float64 `json:"closing"`
+	Currency string  `json:"currency,omitempty"`
+}
+
+// StatementHeader is what a statement says about itself above its transactions. Totals are
+// nil when the statement does not print them.
+type StatementHeader struct {
+	HolderName   string           `json:"holderName,omitempty"`
+	MobileNumber string           `json:"mobileNumber,omitempty"`
+	Period       *StatementPeriod `json:"period,omitempty"`
+	TotalIn      *float64         `json:"totalIn,omitempty"`
+	TotalOut     *float64         `json:"totalOut,omitempty"`
+}
+
+// Warnings are problems found with a statement that did not stop it from being imported.
+type Warnings []string
+
+func (w Warnings) Value() (driver.Value, error) {
+	return json.Marshal(w)
+}
+
+func (w *Warnings) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, w)
+}
+
+// StatementDocument records an uploaded statement: who it belongs to, the period it covers
+// and its balances as the statement states them, and which parser read it. Only M-Pesa
+// statements have their header read, so the holder, mobile number and totals are empty for
+// every other statement type, whose period is that of their transactions.
+type StatementDocument struct {
+	BaseEntity
+
+	UserID         string    `db:"user_id"         json:"userId"`
+	ImportID       string    `db:"import_id"       json:"importId"`
+	Statement      Statement `db:"statement"       json:"statement"`
+	Parser         string    `db:"parser"          json:"parser"`
+	FileName       string    `db:"file_name"       json:"fileName"`
+	FileHash       string    `db:"file_hash"       json:"fileHash"`
+	HolderName     string    `db:"holder_name"     json:"holderName,omitempty"`
+	MobileNumber   string    `db:"mobile_number"   json:"mobileNumber,omitempty"`
+	PeriodFrom     *Date     `db:"period_from"     json:"periodFrom,omitempty"`
+	PeriodTo       *Date     `db:"period_to"       json:"periodTo,omitempty"`
+	OpeningBalance *float64  `db:"opening_balance" json:"openingBalance,omitempty"`
+	ClosingBalance *float64  `db:"closing_balance" json:"closingBalance,omitempty"`
+	TotalIn        *float64  `db:"total_in"        json:"totalIn,omitempty"`
+	TotalOut       *float64  `db:"total_out"       json:"totalOut,omitempty"`
+	Warnings       Warnings  `db:"warnings"        json:"warnings"`
+}
+
+// ParsedStatement is the outcome of parsing a statement.
+type ParsedStatement struct {
+	Incomes  []Income
+	Expenses []Expense
+	Rejected []RejectedRow
+	// Balances is set by parsers of statements that report their opening and closing balances.
+	Balances *StatementBalances
+	// Snapshots and BalanceGaps are set by parsers of statements with a running balance.
+	Snapshots   []BalanceSnapshot
+	BalanceGaps []BalanceGap
+	// Header is set by parsers of statements describing themselves above their transactions,
+	// and Warnings lists where the header disagrees with the transactions.
+	Header   *StatementHeader
+	Warnings Warnings
+
+	balanceRows []balanceRow
+}
+
+type StatementPeriod struct {
+	From Date `json:"from"`
+	To   Date `json:"to"`
+}
+
+// ImportResult reports what an import did with each row of the statement.
+type ImportResult struct {
+	IncomesCreated    int                `json:"incomesCreated"`
+	ExpensesCreated   int                `json:"expensesCreated"`
+	DuplicatesSkipped int                `json:"duplicatesSkipped"`
+	Rejected          []RejectedRow      `json:"rejected"`
+	Period            *StatementPeriod   `json:"period,omitempty"`
+	Balances          *StatementBalances `json:"balances,omitempty"`
+	BalanceGaps       []BalanceGap       `json:"balanceGaps,omitempty"`
+	Warnings          Warnings           `json:"warnings,omitempty"`
+}
+
+func (r ImportResult) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *ImportResult) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, r)
+}
+
+type ImportStatus string
+
+const (
+	ImportQueued     ImportStatus = "queued"
+	ImportExtracting ImportStatus = "extracting"
+	ImportParsing    ImportStatus = "parsing"
+	ImportSaving     ImportStatus = "saving"
+	ImportDone       ImportStatus = "done"
+	ImportFailed     ImportStatus = "failed"
+)
+
+// ImportErrorCode tells why an import job failed, matching the response the upload
+// would have got had the statement been read before answering.
+type ImportErrorCode string
+
+const (
+	ImportInvalidPassword      ImportErrorCode = "invalid_password"
+	ImportUndetectedStatement  ImportErrorCode = "undetected_statement"
+	ImportUnsupportedStatement ImportErrorCode = "unsupported_statement"
+	ImportStatementRejected    ImportErrorCode = "statement_rejected"
+	ImportExtractorTimeout     ImportErrorCode = "extractor_timeout"
+	ImportExtractorUnavailable ImportErrorCode = "extractor_unavailable"
+	ImportInterrupted          ImportErrorCode = "interrupted"
+)
+
+// Statements is a list of statement types stored as JSON.
+type Statements []Statement
+
+func (s Statements) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(s)
+}
+
+func (s *Statements) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, s)
+}
+
+// ImportJob tracks a statement upload while it is processed in the background.
+// The uploaded file is kept until the job finishes; the statement password never is.
+// A failed job carries an error code, and the candidate statement types when the
+// statement type could not be detected. Only jobs that can be rerun from their stored
+// file alone are resumable after a restart.
+type ImportJob struct {
+	BaseEntity
+
+	UserID     string          `db:"user_id"       json:"userId"`
+	Statement  Statement       `db:"statement"     json:"statement"`
+	FileName   string          `db:"file_name"     json:"fileName"`
+	Status     ImportStatus    `db:"status"        json:"status"`
+	Error      string          `db:"error_message" json:"error,omitempty"`
+	ErrorCode  ImportErrorCode `db:"error_code"    json:"errorCode,omitempty"`
+	Candidates Statements      `db:"candidates"    json:"candidates,omitempty"`
+	Result     *ImportResult   `db:"result"        json:"result,omitempty"`
+	Resumable  bool            `db:"resumable"     json:"-"`
+	File       []byte          `db:"file"          json:"-"`
+}
+
+type ImportJobPage struct {
+	Offset  uint64      `json:"offset"`
+	Limit   uint64      `json:"limit"`
+	Total   uint64      `json:"total"`
+	Imports []ImportJob `json:"imports"`
+}
+
+// PreviewIncome is a parsed income flagged when the user already has it.
+type PreviewIncome struct {
+	Income
+
+	Duplicate bool `json:"duplicate"`
+}
+
+// PreviewExpense is a parsed expense flagged when the user already has it.
+type PreviewExpense struct {
+	Expense
+
+	Duplicate bool `json:"duplicate"`
+}
+
+// ImportPreview is what importing a statement would create, without anything being saved.
+// The parsed statement is kept on the server until it is committed with the token.
+type ImportPreview struct {
+	Token       string             `json:"token"`
+	Statement   Statement          `json:"statement"`
+	FileName    string             `json:"fileName"`
+	Incomes     []PreviewIncome    `json:"incomes"`
+	Expenses    []PreviewExpense   `json:"expenses"`
+	Rejected    []RejectedRow      `json:"rejected"`
+	Period      *StatementPeriod   `json:"period,omitempty"`
+	Balances    *StatementBalances `json:"balances,omitempty"`
+	Snapshots   []BalanceSnapshot  `json:"snapshots,omitempty"`
+	BalanceGaps []BalanceGap       `json:"balanceGaps,omitempty"`
+	Header      *StatementHeader   `json:"header,omitempty"`
+	Warnings    Warnings           `json:"warnings,omitempty"`
+}
+
+// ImportCommit saves a preview by its token, with the categories the user changed.
+type ImportCommit struct {
+	Token    string         `json:"token"`
+	Incomes  []CategoryEdit `json:"incomes,omitempty"`
+	Expenses []CategoryEdit `json:"expenses,omitempty"`
+}
+
+// CategoryEdit sets the category of the previewed income or expense at the index.
+type CategoryEdit struct {
+	Index    int      `json:"index"`
+	Category Category `json:"category"`
+}
+
+// Duplicates are the imported transactions that were skipped because the user already has them.
+type Duplicates struct {
+	Incomes  []Income  `json:"incomes"`
+	Expenses []Expense `json:"expenses"`
+}
+
+// Reversal is a transaction of an import batch that reverses one the user imported earlier.
+type Reversal struct {
+	UserID string
+	// DedupeKey identifies the reversal among the batch's incomes, or expenses if not Income.
+	DedupeKey string
+	Income    bool
+	// Receipt is the receipt number of the reversed transaction, ReversedBy that of the reversal.
+	Receipt    string
+	ReversedBy string
+}
+
+// ImportBatch is stored in a single database transaction: its incomes and expenses are
+// inserted and the earlier transactions its reversals undo are canceled.
+type ImportBatch struct {
+	Incomes   []Income
+	Expenses  []Expense
+	Reversals []Reversal
+}
+
+// ImportedBatch reports the transactions of a batch that were skipped as duplicates and the
+// months of the expenses its reversals canceled.
+type ImportedBatch struct {
+	Duplicates Duplicates
+	Months     []string
+}
+
+// CSVMapping describes which columns of a CSV export hold each transaction field.
+// Columns are matched on their header, ignoring case. Amounts come either from a single
+// signed column, negative for money out, or from separate debit and credit columns.
+type CSVMapping struct {
+	DateColumn string `json:"dateColumn"`
+	// DateFormat is a Go reference layout such as "02/01/2006". It defaults to "2006-01-02".
+	DateFormat        string `json:"dateFormat,omitempty"`
+	AmountColumn      string `json:"amountColumn,omitempty"`
+	DebitColumn       string `json:"debitColumn,omitempty"`
+	CreditColumn      string `json:"creditColumn,omitempty"`
+	DescriptionColumn string `json:"descriptionColumn"`
+	// ReferenceColumn optionally holds a transaction reference used to recognise duplicates.
+	ReferenceColumn string `json:"referenceColumn,omitempty"`
+	// CurrencyColumn optionally holds each row's currency, otherwise Currency is used.
+	CurrencyColumn string `json:"currencyColumn,omitempty"`
+	Currency       string `json:"currency,omitempty"`
+}
+
+func (m CSVMapping) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *CSVMapping) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, m)
+}
+
+// CSVMappingPreset is a column mapping saved under a name so that a recurring export
+// can be imported again without describing its columns.
+type CSVMappingPreset struct {
+	BaseEntity
+
+	UserID  string     `db:"user_id" json:"userId"`
+	Name    string     `db:"name"    json:"name"`
+	Mapping CSVMapping `db:"mapping" json:"mapping"`
+}
+
+// MpesaShortcode is a till or paybill number whose Daraja callbacks are recorded for the user.
+// Callback URLs carry the secret issued at registration in their path, as in
+// /daraja/{shortcode}/{secret}/c2b/confirmation, of which only the hash is stored.
+type MpesaShortcode struct {
+	BaseEntity
+
+	UserID        string        `db:"user_id"        json:"userId"`
+	Shortcode     string        `db:"shortcode"      json:"shortcode"`
+	PaymentMethod PaymentMethod `db:"payment_method" json:"paymentMethod"`
+	SecretHash    string        `db:"secret_hash"    json:"-"`
+	// Secret is only set on the shortcode returned by its registration.
+	Secret string `db:"-" json:"secret,omitempty"`
 }
 
 type Query struct {
@@ -220,6 +600,44 @@ type ExpensePage struct {
 	Expenses []Expense `json:"expenses"`
 }
 
+// MonthlyFees are the transaction fees a user paid in a month, in total and per payment method.
+type MonthlyFees struct {
+	Month           string                    `json:"month"`
+	Total           float64                   `json:"total"`
+	Transactions    int                       `json:"transactions"`
+	ByPaymentMethod map[PaymentMethod]float64 `json:"byPaymentMethod"`
+}
+
+type FeeReport struct {
+	From   string        `json:"from"`
+	To     string        `json:"to"`
+	Total  float64       `json:"total"`
+	Months []MonthlyFees `json:"months"`
+}
+
+// BalanceSnapshot is the balance of an account after a transaction, as a statement printed it.
+// Accounts are identified by their statement type, as users have one account of each.
+type BalanceSnapshot struct {
+	BaseEntity
+
+	UserID    string    `db:"user_id"    json:"userId"`
+	Account   Statement `db:"account"    json:"account"`
+	Date      time.Time `db:"date"       json:"date"`
+	Balance   float64   `db:"balance"    json:"balance"`
+	ReceiptNo string    `db:"receipt_no" json:"receiptNo,omitempty"`
+	ImportID  string    `db:"import_id"  json:"importId,omitempty"`
+}
+
+// BalanceGap is a statement row whose balance is not the balance before it plus its amount,
+// because rows between the two are missing from the statement or one of them was misread.
+type BalanceGap struct {
+	Page      uint64  `json:"page"`
+	Row       int     `json:"row"`
+	ReceiptNo string  `json:"receiptNo,omitempty"`
+	Expected  float64 `json:"expected"`
+	Balance   float64 `json:"balance"`
+}
+
 type Budget struct {
 	BaseEntity
 
@@ -0,0 +1,145 @@
+package dolla
+
+import (
+	"strings"
+	"time"
+)
+
+// Equity Bank statements list transactions in seven columns:
+// transaction date, value date, narrative, reference, debit, credit and running balance.
+// Long narratives wrap onto following rows that carry neither a date nor an amount.
+var equityDateLayouts = []string{ //nolint:gochecknoglobals
+	"02-01-2006", "02/01/2006", "02-Jan-2006", "02 Jan 2006", "02-01-06", time.DateOnly,
+}
+
+type equityParser struct{}
+
+// equityRow is a transaction row together with the narrative lines that wrapped below it.
+type equityRow struct {
+	page      uint64
+	row       int
+	table     Table
+	narration []string
+}
+
+func (equityParser) Name() string {
+	return "Equity Bank Statement"
+}
+
+func (equityParser) Detect(pages []ExtractionResponse) bool {
+	title := bankStatementTitle(pages)
+
+	return (strings.Contains(title, "EQUITY BANK") || strings.Contains(title, "EQUITYBANKGROUP.COM")) &&
+		hasValueDateHeader(pages)
+}
+
+func (equityParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+
+	// Narratives may wrap across a page break, so rows are collected over the whole statement.
+	var current *equityRow
+	flush := func() {
+		if current != nil {
+			parseEquityRow(&parsed, *current, pack)
+			current = nil
+		}
+	}
+
+	for _, page := range pages {
+		row := -1
+		for _, tables := range page.Tables {
+			for _, table := range tables {
+				row++
+
+				if isEquityContinuation(table) {
+					if current != nil {
+						current.narration = append(current.narration, table.Two)
+					}
+
+					continue
+				}
+
+				_, debitErr := parseAmount(table.Four)
+				_, creditErr := parseAmount(table.Five)
+				if debitErr != nil && creditErr != nil {
+					// Column headers repeat on every page and carry no amounts.
+					continue
+				}
+
+				flush()
+				current = &equityRow{
+					page:      page.Page,
+					row:       row,
+					table:     table,
+					narration: []string{table.Two},
+				}
+			}
+		}
+	}
+	flush()
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
+
+// isEquityContinuation reports whether the row only holds the wrapped part of a narrative.
+func isEquityContinuation(table Table) bool {
+	return strings.TrimSpace(table.Zero) == "" &&
+		strings.TrimSpace(table.One) == "" &&
+		strings.TrimSpace(table.Four) == "" &&
+		strings.TrimSpace(table.Five) == "" &&
+		strings.TrimSpace(table.Two) != ""
+}
+
+func parseEquityRow(parsed *ParsedStatement, row equityRow, pack CountryPack) {
+	table := row.table
+
+	debit, debitErr := parseAmount(table.Four)
+	credit, creditErr := parseAmount(table.Five)
+	if debitErr == nil && creditErr == nil && debit == 0 && credit == 0 {
+		// Opening and closing balance rows.
+		return
+	}
+
+	date, err := parseDate(table.Zero, equityDateLayouts...)
+	if err != nil {
+		parsed.reject(row.page, row.row, "invalid transaction date %q", table.Zero)
+
+		return
+	}
+
+	description := normaliseNarration(strings.Join(row.narration, " "))
+	if description == "" {
+		parsed.reject(row.page, row.row, "missing narrative")
+
+		return
+	}
+
+	switch {
+	case debitErr != nil:
+		parsed.reject(row.page, row.row, "invalid debit amount %q", table.Four)
+
+		return
+	case creditErr != nil:
+		parsed.reject(row.page, row.row, "invalid credit amount %q", table.Five)
+
+		return
+	}
+
+	meta := Metadata{
+		"reference":       strings.TrimSpace(table.Three),
+		"transactionDate": strings.TrimSpace(table.Zero),
+		"valueDate":       strings.TrimSpace(table.One),
+		"balance":         strings.TrimSpace(table.Six),
+	}
+
+	parsed.addBankTransaction(pack, date, description, debit, credit, toEquityPaymentMethod(description), meta)
+}
+
+func toEquityPaymentMethod(description string) PaymentMethod {
+	if strings.Contains(strings.ToUpper(description), "EQUITEL") {
+		return EquitelMoney
+	}
+
+	return toBankPaymentMethod(description)
+}
@@ -0,0 +1,32 @@
+package dolla
+
+import "testing"
+
+func TestEquityParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, equityParser{}, []parserCase{
+		{fixture: "equity.json", golden: "equity.golden"},
+	})
+}
+
+func TestEquityParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := equityParser{}.Parse(readPages(t, "equity.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Narratives wrapped onto following rows, even across a page break, are joined.
+	checkRows(t, parsed, map[string]parsedRow{
+		"EQUITEL PURCHASE AIRTIME 0763***444":         {amount: 100, method: EquitelMoney},
+		"CASH DEPOSIT BY JANE DOE":                    {income: true, amount: 15000, method: Cash},
+		"MPESA B2C TRANSFER TO 0722***111 JOHN SMITH": {amount: 2500, method: MpesaPaybill},
+		"SUPERMARKET PAYMENT TO QUICKMART KILIMANI":   {amount: 4320, method: BankTransfer},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 2, Row: 2, Reason: `invalid transaction date "31-02-2024"`},
+		{Page: 2, Row: 3, Reason: `invalid credit amount "1,5OO.00"`},
+	})
+}
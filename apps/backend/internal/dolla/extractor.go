@@ -0,0 +1,287 @@
+package dolla
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxErrorBody caps how much of an extractor error response is kept for the error message.
+	maxErrorBody = 1 << 10
+	// passwordErrorCode is the detail code of the extractor's 422 for a wrong or missing
+	// password, which tells it apart from FastAPI's own request validation 422.
+	passwordErrorCode = "invalid_password"
+)
+
+var (
+	// ErrInvalidPassword is returned when an encrypted statement could not be unlocked
+	// because the password was missing or wrong.
+	ErrInvalidPassword = errors.New("incorrect or missing statement password")
+	// ErrStatementRejected is returned when the extractor refuses the file, e.g. because it is
+	// not a readable PDF. Rejections are the statement's fault, so they never open the breaker.
+	ErrStatementRejected = errors.New("pdf extractor rejected the statement")
+	// ErrExtractorUnavailable is returned when the extractor fails, cannot be reached
+	// or has failed too often recently.
+	ErrExtractorUnavailable = errors.New("pdf extractor unavailable")
+	// ErrExtractorTimeout is returned when the extractor does not answer in time.
+	ErrExtractorTimeout = errors.New("pdf extractor timed out")
+)
+
+type Table struct {
+	Zero  string `json:"0"`
+	One   string `json:"1"`
+	Two   string `json:"2"`
+	Three string `json:"3"`
+	Four  string `json:"4"`
+	Five  string `json:"5"`
+	Six   string `json:"6"`
+}
+
+type ExtractionResponse struct {
+	Page   uint64    `json:"page"`
+	Text   string    `json:"text"`
+	Tables [][]Table `json:"tables"`
+}
+
+type ExtractorConfig struct {
+	URL string
+	// Timeout bounds each request to the extractor, including reading the response.
+	Timeout time.Duration
+	// MaxRetries is the number of times a request failing with a 5xx or a network error is retried.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled on every further retry.
+	RetryBackoff time.Duration
+	// BreakerThreshold is the number of consecutive failures after which requests are refused.
+	BreakerThreshold int
+	// BreakerCooldown is how long requests are refused once the breaker opens.
+	BreakerCooldown time.Duration
+}
+
+type extractorClient struct {
+	cfg     ExtractorConfig
+	client  *http.Client
+	breaker *breaker
+}
+
+func NewExtractorClient(cfg ExtractorConfig) Extractor {
+	return &extractorClient{
+		cfg:    cfg,
+		client: &http.Client{},
+		breaker: &breaker{
+			threshold: cfg.BreakerThreshold,
+			cooldown:  cfg.BreakerCooldown,
+		},
+	}
+}
+
+// Extract sends the statement to the pdf-extractor and returns the extracted pages.
+// The file is rewound before every attempt. The password, if any, is only forwarded
+// to the extractor to unlock the PDF.
+func (e *extractorClient) Extract(
+	ctx context.Context, file io.ReadSeeker, password string,
+) ([]ExtractionResponse, error) {
+	if !e.breaker.allow() {
+		return nil, fmt.Errorf("%w: too many recent failures", ErrExtractorUnavailable)
+	}
+
+	backoff := e.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		pages, err := e.extract(ctx, file, password)
+		switch {
+		case err == nil:
+			e.breaker.success()
+
+			return pages, nil
+		case ctx.Err() != nil:
+			return nil, err
+		case !errors.Is(err, ErrExtractorUnavailable) && !errors.Is(err, ErrExtractorTimeout):
+			// The extractor answered; the problem is the statement itself.
+			e.breaker.success()
+
+			return nil, err
+		}
+
+		e.breaker.failure()
+		if attempt >= e.cfg.MaxRetries || !e.breaker.allow() {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (e *extractorClient) extract(
+	ctx context.Context, file io.ReadSeeker, password string,
+) ([]ExtractionResponse, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if e.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.cfg.Timeout)
+		defer cancel()
+	}
+
+	// Stream the multipart body instead of buffering the whole statement in memory. The
+	// writer is stopped and waited for before returning, so that it no longer reads the file
+	// when the next attempt rewinds it.
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		writer.CloseWithError(writeForm(form, file, password))
+	}()
+	defer func() {
+		body.Close()
+		<-written
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, classifyTransportError(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		body := readErrorBody(res.Body)
+		switch {
+		case res.StatusCode == http.StatusUnprocessableEntity && isPasswordError(body):
+			return nil, ErrInvalidPassword
+		case res.StatusCode >= http.StatusInternalServerError:
+			return nil, fmt.Errorf("%w: status %d: %s", ErrExtractorUnavailable, res.StatusCode, body)
+		default:
+			return nil, fmt.Errorf("%w: status %d: %s", ErrStatementRejected, res.StatusCode, body)
+		}
+	}
+
+	var response []ExtractionResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		if transportErr := classifyTransportError(err); errors.Is(transportErr, ErrExtractorTimeout) {
+			return nil, transportErr
+		}
+
+		return nil, fmt.Errorf("%w: invalid response: %w", ErrExtractorUnavailable, err)
+	}
+
+	return response, nil
+}
+
+func writeForm(form *multipart.Writer, file io.Reader, password string) error {
+	fileName := filepath.Base(uuid.NewString() + ".pdf")
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+
+	if password != "" {
+		if err := form.WriteField("password", password); err != nil {
+			return err
+		}
+	}
+
+	return form.Close()
+}
+
+func classifyTransportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrExtractorTimeout, err)
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrExtractorUnavailable, err)
+}
+
+// isPasswordError reports whether an extractor error body is the one it sends for a wrong
+// or missing password, {"detail": {"code": "invalid_password", ...}}.
+func isPasswordError(body string) bool {
+	var response struct {
+		Detail json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		return false
+	}
+
+	var detail struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(response.Detail, &detail); err != nil {
+		return false
+	}
+
+	return detail.Code == passwordErrorCode
+}
+
+func readErrorBody(body io.Reader) string {
+	b, err := io.ReadAll(io.LimitReader(body, maxErrorBody))
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(b)
+}
+
+// breaker is a circuit breaker that refuses requests for a cooldown period after
+// a run of consecutive failures.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return time.Now().After(b.openUntil)
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		b.failures = 0
+	}
+}
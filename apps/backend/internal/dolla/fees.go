@@ -0,0 +1,50 @@
+package dolla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// monthLayout is the layout of the months budgets and reports are kept for.
+const monthLayout = "2006-01"
+
+// feeReportMonths is the number of months a fee report covers by default.
+const feeReportMonths = 12
+
+// ErrInvalidMonth is returned when a month is not formatted as YYYY-MM.
+var ErrInvalidMonth = errors.New("invalid month, expected YYYY-MM")
+
+func (s *service) GetFeeReport(ctx context.Context, userID, from, to string) (FeeReport, error) {
+	now := time.Now().UTC()
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if to != "" {
+		var err error
+		if end, err = time.Parse(monthLayout, to); err != nil {
+			return FeeReport{}, fmt.Errorf("%w: %q", ErrInvalidMonth, to)
+		}
+	}
+	start := end.AddDate(0, 1-feeReportMonths, 0)
+	if from != "" {
+		var err error
+		if start, err = time.Parse(monthLayout, from); err != nil {
+			return FeeReport{}, fmt.Errorf("%w: %q", ErrInvalidMonth, from)
+		}
+	}
+
+	report := FeeReport{
+		From: start.Format(monthLayout),
+		To:   end.Format(monthLayout),
+	}
+	months, err := s.repo.ListMonthlyFees(ctx, userID, report.From, report.To)
+	if err != nil {
+		return FeeReport{}, err
+	}
+	report.Months = months
+	for i := range months {
+		report.Total += months[i].Total
+	}
+
+	return report, nil
+}
@@ -0,0 +1,155 @@
+package dolla
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata") //nolint:gochecknoglobals
+
+// readFixture returns the contents of a file in testdata.
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %s", name, err)
+	}
+
+	return data
+}
+
+// readPages returns the extractor response stored in testdata.
+func readPages(t *testing.T, name string) []ExtractionResponse {
+	t.Helper()
+
+	var pages []ExtractionResponse
+	if err := json.Unmarshal(readFixture(t, name), &pages); err != nil {
+		t.Fatalf("failed to decode fixture %s: %s", name, err)
+	}
+
+	return pages
+}
+
+// checkGolden compares got, encoded as indented JSON, with the golden file in testdata,
+// rewriting the file instead when the tests run with -update.
+func checkGolden(t *testing.T, name string, got any) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode %s: %s", name, err)
+	}
+	data = append(data, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("failed to update golden file %s: %s", name, err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file %s: %s", name, err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s mismatch, run go test -update to accept\ngot:\n%s\nwant:\n%s", name, data, want)
+	}
+}
+
+// parserCase is a statement fixture and the golden file of what its parser reads from it.
+type parserCase struct {
+	fixture string
+	golden  string
+}
+
+// checkStatementParser runs the parser over each extractor response fixture with the Kenyan
+// pack, checking that it recognises the statement and reads the golden transactions.
+func checkStatementParser(t *testing.T, parser StatementParser, cases []parserCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			t.Parallel()
+
+			pages := readPages(t, tc.fixture)
+			if !parser.Detect(pages) {
+				t.Fatalf("%s not detected by %s", tc.fixture, parser.Name())
+			}
+
+			parsed, err := parser.Parse(pages, PackFor(Kenya))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			checkGolden(t, tc.golden, parsed)
+		})
+	}
+}
+
+// checkFileParser is checkStatementParser for structured statement files.
+func checkFileParser(t *testing.T, parser FileParser, cases []parserCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			t.Parallel()
+
+			file := readFixture(t, tc.fixture)
+			if !parser.Detect(file) {
+				t.Fatalf("%s not detected by %s", tc.fixture, parser.Name())
+			}
+
+			parsed, err := parser.Parse(file, PackFor(Kenya))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			checkGolden(t, tc.golden, parsed)
+		})
+	}
+}
+
+// parsedRow is what a parser read from a statement row: whether money came in, how much
+// and how it was paid.
+type parsedRow struct {
+	income bool
+	amount float64
+	method PaymentMethod
+}
+
+// checkRows compares the parsed transactions, keyed by description, with the rows the
+// statement should have been read as.
+func checkRows(t *testing.T, parsed ParsedStatement, want map[string]parsedRow) {
+	t.Helper()
+
+	got := make(map[string]parsedRow)
+	for i := range parsed.Incomes {
+		income := parsed.Incomes[i]
+		got[income.Description] = parsedRow{income: true, amount: income.Amount, method: income.PaymentMethod}
+	}
+	for i := range parsed.Expenses {
+		expense := parsed.Expenses[i]
+		got[expense.Description] = parsedRow{amount: expense.Amount, method: expense.PaymentMethod}
+	}
+
+	if !maps.Equal(got, want) {
+		t.Errorf("got rows %+v, want %+v", got, want)
+	}
+}
+
+// checkRejected compares the rows a parser rejected with the rows it should have.
+func checkRejected(t *testing.T, parsed ParsedStatement, want []RejectedRow) {
+	t.Helper()
+
+	if !slices.Equal(parsed.Rejected, want) {
+		t.Errorf("got rejected rows %+v, want %+v", parsed.Rejected, want)
+	}
+}
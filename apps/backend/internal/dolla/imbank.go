@@ -0,0 +1,33 @@
+package dolla
+
+import (
+	"strings"
+	"time"
+)
+
+// I&M Bank statements use the common seven column layout read by parseBankPage.
+var imBankDateLayouts = []string{ //nolint:gochecknoglobals
+	"02-Jan-2006", "02 Jan 2006", "02/01/2006", "02-01-2006", time.DateOnly,
+}
+
+type imBankParser struct{}
+
+func (imBankParser) Name() string {
+	return "I&M Bank Statement"
+}
+
+func (imBankParser) Detect(pages []ExtractionResponse) bool {
+	title := bankStatementTitle(pages)
+
+	return (strings.Contains(title, "I&M BANK") || strings.Contains(title, "IMBANK.COM")) && hasValueDateHeader(pages)
+}
+
+func (imBankParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseBankPage(pages[i], newBankLayout(imBankDateLayouts), pack))
+	}
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
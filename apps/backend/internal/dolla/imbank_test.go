@@ -0,0 +1,32 @@
+package dolla
+
+import "testing"
+
+func TestIMBankParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, imBankParser{}, []parserCase{
+		{fixture: "imbank.json", golden: "imbank.golden"},
+	})
+}
+
+func TestIMBankParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := imBankParser{}.Parse(readPages(t, "imbank.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Credits are incomes and debits expenses, while balance rows are skipped.
+	checkRows(t, parsed, map[string]parsedRow{
+		"RTGS FROM ACME LTD":    {income: true, amount: 40000, method: BankTransfer},
+		"M-PESA PAYBILL KPLC":   {amount: 2000, method: MpesaPaybill},
+		"CHEQUE 000123 DEPOSIT": {income: true, amount: 3000, method: Cheque},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 1, Row: 4, Reason: `invalid transaction date "31-Feb-2024"`},
+		{Page: 2, Row: 1, Reason: `invalid debit amount "1,2OO.00"`},
+		{Page: 2, Row: 2, Reason: "missing narration"},
+	})
+}
@@ -0,0 +1,396 @@
+package dolla
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"sync"
+)
+
+const importQueueSize = 100
+
+var (
+	// ErrImportQueueFull is returned when a statement is uploaded while the import queue is full.
+	ErrImportQueueFull = errors.New("too many imports in progress, try again later")
+	// ErrImportInterrupted fails the jobs a restart cut short that cannot be run again,
+	// such as encrypted statements, whose password was never stored.
+	ErrImportInterrupted = errors.New("import was interrupted, upload the statement again")
+	// ErrImportNotFound is returned for an import job that does not exist or belongs to another user.
+	ErrImportNotFound = errors.New("import not found")
+	// ErrImportInProgress is returned when deleting an import that has not finished yet.
+	ErrImportInProgress = errors.New("import still in progress")
+)
+
+// importTask is a job handed to the workers together with the password of its
+// statement, which is deliberately kept out of the database.
+type importTask struct {
+	userID   string
+	jobID    string
+	password string
+}
+
+func (s *service) CreateTransaction(
+	ctx context.Context, userID string, ttype Statement, fileHeader *multipart.FileHeader, password string,
+) (ImportJob, error) {
+	if ttype != "" {
+		if !s.parsers.Supports(ttype) {
+			return ImportJob{}, fmt.Errorf("%w: %s", ErrUnsupportedStatement, ttype)
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return ImportJob{}, err
+	}
+	defer file.Close()
+
+	// The statement is stored whole with the job, where the import worker reads it from and
+	// where a restart resumes it from; only its upload to the extractor is streamed.
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	job := ImportJob{
+		UserID:    userID,
+		Statement: ttype,
+		FileName:  fileHeader.Filename,
+		Status:    ImportQueued,
+		Resumable: password == "",
+		File:      data,
+	}
+	job.PopulateDataOnCreate(ctx)
+
+	// Mark the job before it is stored so resumeImports cannot pick it up as well.
+	s.queued.Store(job.ID, struct{}{})
+	if err := s.repo.CreateImportJob(ctx, job); err != nil {
+		s.queued.Delete(job.ID)
+
+		return ImportJob{}, err
+	}
+
+	select {
+	case s.imports <- importTask{userID: userID, jobID: job.ID, password: password}:
+	default:
+		// Refuse the upload rather than wait for a slot the client may give up on.
+		s.queued.Delete(job.ID)
+		s.failImport(context.WithoutCancel(ctx), &job, ErrImportQueueFull)
+
+		return ImportJob{}, ErrImportQueueFull
+	}
+	job.File = nil
+
+	return job, nil
+}
+
+func (s *service) GetImportJob(ctx context.Context, userID, id string) (ImportJob, error) {
+	return s.repo.GetImportJob(ctx, userID, id)
+}
+
+func (s *service) ListImportJobs(ctx context.Context, userID string, query Query) (ImportJobPage, error) {
+	return s.repo.ListImportJobs(ctx, userID, query)
+}
+
+func (s *service) DeleteImport(ctx context.Context, userID, id string) error {
+	job, err := s.repo.GetImportJob(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != ImportDone && job.Status != ImportFailed {
+		return fmt.Errorf("%w: import %s is %s", ErrImportInProgress, id, job.Status)
+	}
+
+	months, err := s.repo.DeleteImport(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	for _, month := range months {
+		if err := s.repo.CalculateBudgetProgress(ctx, userID, month); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) ProcessImports(ctx context.Context, workers int) error {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case task := <-s.imports:
+					// Let the job finish even when shutdown starts halfway through it.
+					s.processImport(context.WithoutCancel(ctx), task)
+				}
+			}
+		}()
+	}
+
+	if err := s.resumeImports(ctx); err != nil {
+		slog.Error("failed to resume import jobs", slog.String("err", err.Error()))
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// resumeImports queues the jobs left unfinished by a previous run. Jobs that are not
+// resumable, such as encrypted statements whose password was never stored, fail with
+// ErrImportInterrupted instead.
+func (s *service) resumeImports(ctx context.Context) error {
+	jobs, err := s.repo.ListPendingImportJobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		if _, loaded := s.queued.LoadOrStore(jobs[i].ID, struct{}{}); loaded {
+			continue
+		}
+		if !jobs[i].Resumable {
+			s.failImport(ctx, &jobs[i], ErrImportInterrupted)
+			s.queued.Delete(jobs[i].ID)
+
+			continue
+		}
+
+		select {
+		case s.imports <- importTask{userID: jobs[i].UserID, jobID: jobs[i].ID}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *service) processImport(ctx context.Context, task importTask) {
+	defer s.queued.Delete(task.jobID)
+
+	job, err := s.repo.GetImportJob(ctx, task.userID, task.jobID)
+	if err != nil {
+		slog.Error("failed to load import job", slog.String("id", task.jobID), slog.String("err", err.Error()))
+
+		return
+	}
+
+	result, err := s.runImport(ctx, &job, task.password)
+	if err != nil {
+		s.failImport(ctx, &job, err)
+
+		return
+	}
+
+	job.Result = &result
+	s.setImportStatus(ctx, &job, ImportDone)
+}
+
+func (s *service) runImport(ctx context.Context, job *ImportJob, password string) (ImportResult, error) {
+	file, err := s.repo.GetImportJobFile(ctx, job.ID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	pack := s.countryPack(ctx, job.UserID)
+	var parsed ParsedStatement
+	if ttype, parser, ok := s.fileParser(job.Statement, file); ok {
+		job.Statement = ttype
+		s.setImportStatus(ctx, job, ImportParsing)
+		if parsed, err = parser.Parse(file, pack); err != nil {
+			return ImportResult{}, err
+		}
+	} else {
+		s.setImportStatus(ctx, job, ImportExtracting)
+		var pages []ExtractionResponse
+		if pages, err = s.extractor.Extract(ctx, bytes.NewReader(file), password); err != nil {
+			return ImportResult{}, err
+		}
+
+		s.setImportStatus(ctx, job, ImportParsing)
+		if job.Statement, parsed, err = s.parseStatement(job.Statement, pages, pack); err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	s.setImportStatus(ctx, job, ImportSaving)
+	result, err := s.importTransactions(ctx, job.UserID, job.ID, parsed)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	s.recordDocument(ctx, job, hashFile(file), parsed)
+
+	return result, nil
+}
+
+// fileParser returns the parser of a structured statement file such as OFX or QIF,
+// detecting the file type from its contents when ttype is empty. It reports false for
+// PDF statements, which go through the pdf-extractor instead.
+func (s *service) fileParser(ttype Statement, file []byte) (Statement, FileParser, bool) {
+	if ttype == "" {
+		var ok bool
+		if ttype, ok = s.parsers.DetectFile(file); !ok {
+			return "", nil, false
+		}
+	}
+
+	parser, ok := s.parsers.GetFile(ttype)
+
+	return ttype, parser, ok
+}
+
+// parseStatement parses the extracted pages with the parser of the statement type,
+// detecting the type first when it is empty.
+func (s *service) parseStatement(
+	ttype Statement, pages []ExtractionResponse, pack CountryPack,
+) (Statement, ParsedStatement, error) {
+	if ttype == "" {
+		var err error
+		if ttype, err = s.parsers.Detect(pages); err != nil {
+			return "", ParsedStatement{}, err
+		}
+	}
+
+	parser, ok := s.parsers.Get(ttype)
+	if !ok {
+		return "", ParsedStatement{}, fmt.Errorf("%w: %s", ErrUnsupportedStatement, ttype)
+	}
+
+	parsed, err := parser.Parse(pages, pack)
+	if err != nil {
+		return "", ParsedStatement{}, err
+	}
+	for i := range parsed.Snapshots {
+		parsed.Snapshots[i].Account = ttype
+	}
+
+	return ttype, parsed, nil
+}
+
+// stampTransactions assigns parsed transactions to the user and computes their dedupe keys.
+func stampTransactions(userID string, incomes []Income, expenses []Expense) {
+	for i := range incomes {
+		incomes[i].UserID = userID
+		incomes[i].DedupeKey = dedupeKey(
+			incomes[i].Meta, incomes[i].Date, incomes[i].Amount, incomes[i].Description,
+		)
+	}
+	for i := range expenses {
+		expenses[i].UserID = userID
+		expenses[i].DedupeKey = dedupeKey(
+			expenses[i].Meta, expenses[i].Date, expenses[i].Amount, expenses[i].Description,
+		)
+	}
+}
+
+// importTransactions stores parsed statement transactions for the user as part of
+// the import batch, skipping any the user already has.
+func (s *service) importTransactions(
+	ctx context.Context, userID, importID string, parsed ParsedStatement,
+) (ImportResult, error) {
+	s.countryPack(ctx, userID).localise(&parsed)
+	incomes, expenses := parsed.Incomes, parsed.Expenses
+	stampTransactions(userID, incomes, expenses)
+	for i := range incomes {
+		incomes[i].ImportID = importID
+		incomes[i].PopulateDataOnCreate(ctx)
+	}
+	for i := range expenses {
+		expenses[i].ImportID = importID
+		expenses[i].PopulateDataOnCreate(ctx)
+	}
+
+	imported, err := s.repo.ImportTransactions(ctx, ImportBatch{
+		Incomes:   incomes,
+		Expenses:  expenses,
+		Reversals: earlierReversals(userID, incomes, expenses),
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	duplicates := imported.Duplicates
+
+	// Expenses canceled by the batch's reversals no longer count towards their budgets.
+	for _, month := range imported.Months {
+		if err := s.repo.CalculateBudgetProgress(ctx, userID, month); err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	snapshots := parsed.Snapshots
+	for i := range snapshots {
+		snapshots[i].UserID = userID
+		snapshots[i].ImportID = importID
+		snapshots[i].PopulateDataOnCreate(ctx)
+	}
+	if err := s.repo.CreateBalanceSnapshots(ctx, snapshots); err != nil {
+		return ImportResult{}, err
+	}
+
+	rejected := parsed.Rejected
+	if rejected == nil {
+		rejected = []RejectedRow{}
+	}
+
+	return ImportResult{
+		IncomesCreated:    len(incomes) - len(duplicates.Incomes),
+		ExpensesCreated:   len(expenses) - len(duplicates.Expenses),
+		DuplicatesSkipped: len(duplicates.Incomes) + len(duplicates.Expenses),
+		Rejected:          rejected,
+		Period:            parsed.period(),
+		Balances:          parsed.Balances,
+		BalanceGaps:       parsed.BalanceGaps,
+		Warnings:          parsed.Warnings,
+	}, nil
+}
+
+// failImport marks the job failed with the error and the code telling why.
+func (s *service) failImport(ctx context.Context, job *ImportJob, err error) {
+	job.Error = err.Error()
+	job.ErrorCode, job.Candidates = importErrorCode(err)
+	s.setImportStatus(ctx, job, ImportFailed)
+}
+
+// importErrorCode classifies an import error the way the API maps it to a status,
+// returning the candidate statement types when the type could not be detected.
+func importErrorCode(err error) (ImportErrorCode, Statements) {
+	var undetected *UndetectedStatementError
+	switch {
+	case errors.As(err, &undetected):
+		return ImportUndetectedStatement, undetected.Candidates
+	case errors.Is(err, ErrInvalidPassword):
+		return ImportInvalidPassword, nil
+	case errors.Is(err, ErrUnsupportedStatement):
+		return ImportUnsupportedStatement, nil
+	case errors.Is(err, ErrStatementRejected):
+		return ImportStatementRejected, nil
+	case errors.Is(err, ErrExtractorTimeout):
+		return ImportExtractorTimeout, nil
+	case errors.Is(err, ErrExtractorUnavailable):
+		return ImportExtractorUnavailable, nil
+	case errors.Is(err, ErrImportInterrupted):
+		return ImportInterrupted, nil
+	default:
+		return "", nil
+	}
+}
+
+func (s *service) setImportStatus(ctx context.Context, job *ImportJob, status ImportStatus) {
+	job.Status = status
+	job.PopulateDataOnUpdate(ctx)
+
+	if err := s.repo.UpdateImportJob(ctx, *job); err != nil {
+		slog.Error("failed to update import job", slog.String("id", job.ID), slog.String("err", err.Error()))
+	}
+}
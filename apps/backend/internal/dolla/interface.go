@@ -2,9 +2,37 @@ package dolla
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
 )
 
+// Extractor extracts the text and tables of each page of a statement PDF.
+type Extractor interface {
+	Extract(ctx context.Context, file io.ReadSeeker, password string) ([]ExtractionResponse, error)
+}
+
+// StatementParser converts the pages extracted from a statement into incomes and expenses,
+// reporting the rows it could not convert.
+type StatementParser interface {
+	// Name is the human readable name of the statement, e.g. "M-Pesa Statement".
+	Name() string
+	// Detect reports whether the pages look like a statement this parser understands.
+	Detect(pages []ExtractionResponse) bool
+	// Parse categorises transactions with the user's country pack and leaves currencies the
+	// statement does not fix empty for the pack to fill in.
+	Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error)
+}
+
+// FileParser reads statements exported in a structured format, such as OFX or QIF,
+// straight from the uploaded file without the pdf-extractor.
+type FileParser interface {
+	// Name is the human readable name of the format, e.g. "QIF File".
+	Name() string
+	// Detect reports whether the file looks like a statement this parser understands.
+	Detect(file []byte) bool
+	Parse(file []byte, pack CountryPack) (ParsedStatement, error)
+}
+
 type Repository interface {
 	CreateIncome(ctx context.Context, incomes ...Income) error
 	GetIncome(ctx context.Context, userID, id string) (Income, error)
@@ -18,6 +46,49 @@ type Repository interface {
 	UpdateExpense(ctx context.Context, expense Expense) error
 	DeleteExpense(ctx context.Context, userID, id string) error
 
+	// ImportTransactions stores the batch in a single transaction. It skips the incomes and
+	// expenses whose dedupe key the user already has and cancels the transactions reversed by
+	// the batch's reversals, recording the reversal in their metadata. Reversals that were
+	// skipped cancel nothing, and those whose transaction the user does not have are kept.
+	ImportTransactions(ctx context.Context, batch ImportBatch) (ImportedBatch, error)
+	// FindDuplicates returns the incomes and expenses whose dedupe key the user already has.
+	FindDuplicates(ctx context.Context, userID string, incomes []Income, expenses []Expense) (Duplicates, error)
+	// CreateBalanceSnapshots stores the snapshots, skipping those the user already has.
+	CreateBalanceSnapshots(ctx context.Context, snapshots []BalanceSnapshot) error
+	// ListBalanceSnapshots returns the balance history of the user's account, oldest first.
+	ListBalanceSnapshots(ctx context.Context, userID string, account Statement) ([]BalanceSnapshot, error)
+	CreateStatementDocument(ctx context.Context, document StatementDocument) error
+	GetStatementDocument(ctx context.Context, userID, importID string) (StatementDocument, error)
+
+	CreateImportJob(ctx context.Context, job ImportJob) error
+	// GetImportJob returns ErrImportNotFound if the user has no such import job.
+	GetImportJob(ctx context.Context, userID, id string) (ImportJob, error)
+	// GetImportJobFile returns the uploaded file of a job that has not finished yet.
+	GetImportJobFile(ctx context.Context, id string) ([]byte, error)
+	ListImportJobs(ctx context.Context, userID string, query Query) (ImportJobPage, error)
+	// ListPendingImportJobs returns the jobs of all users that have not finished yet.
+	ListPendingImportJobs(ctx context.Context) ([]ImportJob, error)
+	// UpdateImportJob saves the job's progress, discarding its file once it is done or failed.
+	UpdateImportJob(ctx context.Context, job ImportJob) error
+	// DeleteImport deletes the incomes, expenses and balance snapshots created by the import,
+	// restores the earlier transactions its reversals canceled and soft-deletes the import and
+	// its statement document in a single transaction. It returns the months of the deleted
+	// and restored expenses.
+	DeleteImport(ctx context.Context, userID, id string) ([]string, error)
+
+	// CreateCSVMapping saves the preset, replacing any preset of the user with the same name.
+	CreateCSVMapping(ctx context.Context, preset CSVMappingPreset) error
+	GetCSVMapping(ctx context.Context, userID, id string) (CSVMappingPreset, error)
+	ListCSVMappings(ctx context.Context, userID string) ([]CSVMappingPreset, error)
+	DeleteCSVMapping(ctx context.Context, userID, id string) error
+
+	// CreateShortcode registers the shortcode, failing with ErrShortcodeRegistered if it is already active.
+	CreateShortcode(ctx context.Context, shortcode MpesaShortcode) error
+	// GetShortcode returns the active registration of the shortcode, whichever user it belongs to.
+	GetShortcode(ctx context.Context, shortcode string) (MpesaShortcode, error)
+	ListShortcodes(ctx context.Context, userID string) ([]MpesaShortcode, error)
+	DeleteShortcode(ctx context.Context, userID, id string) error
+
 	GetUserProfile(ctx context.Context, clerkUserID string) (UserProfile, error)
 	CreateUserProfile(ctx context.Context, profile UserProfile) error
 	UpdateUserProfile(ctx context.Context, profile UserProfile) error
@@ -28,11 +99,73 @@ type Repository interface {
 	UpdateBudget(ctx context.Context, budget Budget) error
 	DeleteBudget(ctx context.Context, userID, id string) error
 	GetBudgetSummary(ctx context.Context, userID, month string) (BudgetSummary, error)
+	// ListMonthlyFees returns the fees of the user's expenses in the months from and to, inclusive,
+	// leaving out months without fees.
+	ListMonthlyFees(ctx context.Context, userID, from, to string) ([]MonthlyFees, error)
 	CalculateBudgetProgress(ctx context.Context, userID, month string) error
 }
 
 type Service interface {
-	CreateTransaction(ctx context.Context, userID string, ttype Statement, file *multipart.FileHeader) error
+	// CreateTransaction queues the statement file for import and returns the queued job.
+	// An empty ttype detects the statement type from its contents. The password unlocks
+	// encrypted statements and is only held in memory until the job is processed.
+	// Transactions the user already has are skipped and counted as duplicates.
+	CreateTransaction(
+		ctx context.Context, userID string, ttype Statement, file *multipart.FileHeader, password string,
+	) (ImportJob, error)
+	// PreviewTransaction parses the statement like CreateTransaction but saves nothing,
+	// flagging the transactions the user already has.
+	PreviewTransaction(
+		ctx context.Context, userID string, ttype Statement, file *multipart.FileHeader, password string,
+	) (ImportPreview, error)
+	// CommitTransaction saves a previewed batch in a single transaction and records it as an import.
+	CommitTransaction(ctx context.Context, userID string, commit ImportCommit) (ImportJob, error)
+	// ListStatementTypes lists the statement types offered in the user's country, or every
+	// type when userID is empty.
+	ListStatementTypes(ctx context.Context, userID string) []StatementType
+	ListCountryPacks(ctx context.Context) []CountryPack
+	GetImportJob(ctx context.Context, userID, id string) (ImportJob, error)
+	ListImportJobs(ctx context.Context, userID string, query Query) (ImportJobPage, error)
+	// DeleteImport undoes a finished import and recalculates the budgets of the affected months.
+	// It returns ErrImportInProgress for an import that has not finished yet.
+	DeleteImport(ctx context.Context, userID, id string) error
+	// GetStatementDocument returns what the statement uploaded for the import says about itself.
+	// Documents are recorded for PDF and file uploads, whether imported directly or committed
+	// from a preview; CSV, SMS and Daraja imports have none.
+	GetStatementDocument(ctx context.Context, userID, importID string) (StatementDocument, error)
+	// ListBalances returns the balance history of the user's account, identified by its
+	// statement type, oldest first.
+	ListBalances(ctx context.Context, userID string, account Statement) ([]BalanceSnapshot, error)
+	// ProcessImports runs the given number of import workers until ctx is canceled,
+	// letting in-flight jobs finish. Unfinished jobs are resumed on the next start.
+	ProcessImports(ctx context.Context, workers int) error
+	// ImportCSV imports a CSV export read with the mapping, without the pdf-extractor,
+	// and records it as an import.
+	ImportCSV(ctx context.Context, userID string, file *multipart.FileHeader, mapping CSVMapping) (ImportJob, error)
+	// ImportSMS imports M-Pesa and Airtel Money confirmations pasted as text, or exported
+	// by SMS Backup & Restore when backup is not nil, and records them as an import.
+	ImportSMS(ctx context.Context, userID, text string, backup *multipart.FileHeader) (ImportJob, error)
+
+	// CreateCSVMapping saves a named mapping preset, replacing any preset of the user with the same name.
+	CreateCSVMapping(ctx context.Context, preset CSVMappingPreset) (CSVMappingPreset, error)
+	GetCSVMapping(ctx context.Context, userID, id string) (CSVMappingPreset, error)
+	ListCSVMappings(ctx context.Context, userID string) ([]CSVMappingPreset, error)
+	DeleteCSVMapping(ctx context.Context, userID, id string) error
+
+	// CreateShortcode registers a till or paybill for Daraja callbacks and returns it with
+	// the secret to put in the path of the callback URLs registered with Daraja, which is
+	// not stored and cannot be read again.
+	CreateShortcode(ctx context.Context, shortcode MpesaShortcode) (MpesaShortcode, error)
+	ListShortcodes(ctx context.Context, userID string) ([]MpesaShortcode, error)
+	DeleteShortcode(ctx context.Context, userID, id string) error
+	// ValidateC2B checks that a C2B payment to the shortcode can be accepted.
+	ValidateC2B(ctx context.Context, shortcode, secret string, req DarajaC2BRequest) error
+	// ConfirmC2B records a C2B payment to the shortcode as an income of the shortcode's user.
+	// Repeated confirmations of the same payment are recorded once.
+	ConfirmC2B(ctx context.Context, shortcode, secret string, req DarajaC2BRequest) error
+	// RecordB2CResult records a completed B2C payment from the shortcode as an expense of
+	// the shortcode's user. Failed payments are ignored.
+	RecordB2CResult(ctx context.Context, shortcode, secret string, result DarajaB2CResult) error
 
 	CreateIncome(ctx context.Context, incomes ...Income) error
 	GetIncome(ctx context.Context, userID, id string) (Income, error)
@@ -57,5 +190,8 @@ type Service interface {
 	UpdateBudget(ctx context.Context, budget Budget) error
 	DeleteBudget(ctx context.Context, userID, id string) error
 	GetBudgetSummary(ctx context.Context, userID, month string) (BudgetSummary, error)
+	// GetFeeReport totals the transaction fees the user paid each month from and to, inclusive,
+	// defaulting to the last twelve months.
+	GetFeeReport(ctx context.Context, userID, from, to string) (FeeReport, error)
 	CalculateBudgetProgress(ctx context.Context, userID, month string) error
 }
@@ -0,0 +1,56 @@
+package dolla
+
+import (
+	"strings"
+	"time"
+)
+
+// KCB statements list transactions in seven columns: transaction date, value date,
+// transaction details, bank reference, money out, money in and ledger balance.
+var kcbDateLayouts = []string{ //nolint:gochecknoglobals
+	"02.01.2006", "02 Jan 2006", "02-Jan-2006", "02/01/2006", "02-01-2006", "02 Jan 06", time.DateOnly,
+}
+
+// kcbLayout dates rows that leave the transaction date blank by their value date.
+var kcbLayout = bankLayout{ //nolint:gochecknoglobals
+	dates:             kcbDateLayouts,
+	valueDateFallback: true,
+	narration:         "transaction details",
+	debit:             "money out",
+	credit:            "money in",
+	method:            toKCBPaymentMethod,
+}
+
+type kcbParser struct{}
+
+func (kcbParser) Name() string {
+	return "KCB Bank Statement"
+}
+
+func (kcbParser) Detect(pages []ExtractionResponse) bool {
+	title := bankStatementTitle(pages)
+
+	return (strings.Contains(title, "KCB BANK") || strings.Contains(title, "KCBGROUP.COM")) &&
+		hasValueDateHeader(pages)
+}
+
+func (kcbParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseBankPage(pages[i], kcbLayout, pack))
+	}
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
+
+// toKCBPaymentMethod treats transfers between the account and the linked M-Pesa wallet,
+// narrated as "KCB M-PESA", as M-Pesa transfers rather than paybill payments.
+func toKCBPaymentMethod(description string) PaymentMethod {
+	description = strings.ToUpper(description)
+	if strings.Contains(description, "KCB M-PESA") || strings.Contains(description, "KCB MPESA") {
+		return MpesaSendMoney
+	}
+
+	return toBankPaymentMethod(description)
+}
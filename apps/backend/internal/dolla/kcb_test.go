@@ -0,0 +1,33 @@
+package dolla
+
+import "testing"
+
+func TestKCBParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, kcbParser{}, []parserCase{
+		{fixture: "kcb.json", golden: "kcb.golden"},
+	})
+}
+
+func TestKCBParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := kcbParser{}.Parse(readPages(t, "kcb.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Rows without a transaction date are dated by their value date, and transfers to the
+	// linked M-Pesa wallet are M-Pesa transfers.
+	checkRows(t, parsed, map[string]parsedRow{
+		"KCB M-PESA TRANSFER 0722***555":  {amount: 3000, method: MpesaSendMoney},
+		"STO RENT PAYMENT MARCH":          {amount: 20000, method: BankStandingOrd},
+		"INWARD TRANSFER FROM JOHN SMITH": {income: true, amount: 8500, method: BankTransfer},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 1, Row: 5, Reason: "missing transaction details"},
+		{Page: 1, Row: 6, Reason: `invalid transaction date "09/13/2024"`},
+		{Page: 1, Row: 7, Reason: `invalid money in amount "2.500.00"`},
+	})
+}
@@ -1,186 +1,298 @@
 package dolla
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"io"
-	"mime/multipart"
-	"net/http"
-	"path/filepath"
-	"strconv"
+	"math"
+	"regexp"
 	"strings"
 	"time"
+)
 
-	"github.com/google/uuid"
+var ( //nolint:gochecknoglobals
+	// mpesaReceiptPattern matches M-Pesa receipt numbers such as "QGH4XK2L9P".
+	mpesaReceiptPattern = regexp.MustCompile(`\b[A-Z0-9]{10}\b`)
+
+	// The statement header names the customer and the period, and the summary table on the
+	// first page ends with the totals paid in and paid out, as in "TOTAL: 12,500.00 9,870.00".
+	mpesaHolderPattern = regexp.MustCompile(`(?im)customer name\s*:?[ \t]*(.+)$`)
+	mpesaMobilePattern = regexp.MustCompile(`(?i)mobile number\s*:?\s*(\+?\d[\d ]{7,14}\d)`)
+	mpesaPeriodPattern = regexp.MustCompile(
+		`(?i)statement period\s*:?\s*(\d{1,2} [a-z]{3} \d{4}|\d{4}-\d{2}-\d{2})\s*-\s*` +
+			`(\d{1,2} [a-z]{3} \d{4}|\d{4}-\d{2}-\d{2})`,
+	)
+	mpesaTotalPattern = regexp.MustCompile(`(?im)^\s*total\s*:?\s*(-?[\d,]+\.\d{2})\s+(-?[\d,]+\.\d{2})`)
 )
 
-type Table struct {
-	Zero  string `json:"0"`
-	One   string `json:"1"`
-	Two   string `json:"2"`
-	Three string `json:"3"`
-	Four  string `json:"4"`
-	Five  string `json:"5"`
-	Six   string `json:"6"`
-}
+type mpesaParser struct{}
 
-type ExtractionResponse struct {
-	Page   uint64    `json:"page"`
-	Text   string    `json:"text"`
-	Tables [][]Table `json:"tables"`
+func (mpesaParser) Name() string {
+	return "M-Pesa Statement"
 }
 
-func Mpesa(ctx context.Context, client *http.Client, url string, file multipart.File) ([]Income, []Expense, error) {
-	payload := &bytes.Buffer{}
-	writer := multipart.NewWriter(payload)
-
-	fileName := filepath.Base(uuid.NewString() + ".pdf")
-	part1, err := writer.CreateFormFile("file", fileName)
-	if err != nil {
-		return nil, nil, err
+func (mpesaParser) Detect(pages []ExtractionResponse) bool {
+	text := statementText(pages)
+	if isTanzanianMpesa(text) {
+		return false
 	}
-	if _, err = io.Copy(part1, file); err != nil {
-		return nil, nil, err
+	if strings.Contains(text, "M-PESA STATEMENT") || strings.Contains(text, "MPESA STATEMENT") {
+		return true
 	}
 
-	if err := writer.Close(); err != nil {
-		return nil, nil, err
+	return hasHeader(pages, func(table Table) bool {
+		return strings.HasPrefix(strings.ToUpper(table.Zero), "RECEIPT") &&
+			strings.EqualFold(table.Four, "Paid in")
+	})
+}
+
+func (mpesaParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseMpesaPage(pages[i], PackFor(Kenya), pack))
 	}
+	parsed.linkFees(isMpesaCharge)
+	parsed.linkReversals(isMpesaReversal, mpesaReversedReceipt)
+	parsed.checkBalances()
+	parsed.Header = parseMpesaHeader(pages)
+	parsed.checkHeader()
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
+
+// mpesaTanzaniaParser reads Vodacom M-Pesa statements, which share the layout of Safaricom's.
+type mpesaTanzaniaParser struct{}
+
+func (mpesaTanzaniaParser) Name() string {
+	return "Vodacom M-Pesa Tanzania Statement"
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, payload)
-	if err != nil {
-		return nil, nil, err
+func (mpesaTanzaniaParser) Detect(pages []ExtractionResponse) bool {
+	text := statementText(pages)
+
+	return isTanzanianMpesa(text) && (strings.Contains(text, "M-PESA") || strings.Contains(text, "MPESA"))
+}
+
+// Parse reads the statement with the Tanzanian pack whatever the user's country, as Vodacom
+// statements are only ever in Tanzanian shillings.
+func (mpesaTanzaniaParser) Parse(pages []ExtractionResponse, _ CountryPack) (ParsedStatement, error) {
+	pack := PackFor(Tanzania)
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseMpesaPage(pages[i], pack, pack))
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	parsed.linkFees(isMpesaCharge)
+	parsed.linkReversals(isMpesaReversal, mpesaReversedReceipt)
+	parsed.checkBalances()
+	parsed.Header = parseMpesaHeader(pages)
+	parsed.checkHeader()
+	parsed.issuedIn(Tanzania)
+
+	return parsed, nil
+}
 
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, nil, err
+// isTanzanianMpesa reports whether the statement text is from Vodacom rather than Safaricom.
+// Safaricom statements may name Tanzania in the details of international transfers.
+func isTanzanianMpesa(text string) bool {
+	return strings.Contains(text, "VODACOM")
+}
+
+// parseMpesaHeader reads the customer, the period and the summary totals from the text of
+// the first pages. It returns nil for statements without a header, such as a page range.
+func parseMpesaHeader(pages []ExtractionResponse) *StatementHeader {
+	var text strings.Builder
+	for i := range pages {
+		if i == headerPages {
+			break
+		}
+		text.WriteString(pages[i].Text)
+		text.WriteString("\n")
 	}
-	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, nil, err
+	var header StatementHeader
+	if match := mpesaHolderPattern.FindStringSubmatch(text.String()); match != nil {
+		header.HolderName = normaliseNarration(match[1])
+	}
+	if match := mpesaMobilePattern.FindStringSubmatch(text.String()); match != nil {
+		header.MobileNumber = strings.ReplaceAll(match[1], " ", "")
+	}
+	if match := mpesaPeriodPattern.FindStringSubmatch(text.String()); match != nil {
+		from, fromErr := parseDate(match[1], "02 Jan 2006", "2 Jan 2006", time.DateOnly)
+		to, toErr := parseDate(match[2], "02 Jan 2006", "2 Jan 2006", time.DateOnly)
+		if fromErr == nil && toErr == nil {
+			header.Period = &StatementPeriod{From: Date{from}, To: Date{to}}
+		}
+	}
+	if match := mpesaTotalPattern.FindStringSubmatch(text.String()); match != nil {
+		paidIn, inErr := parseAmount(match[1])
+		paidOut, outErr := parseAmount(match[2])
+		if inErr == nil && outErr == nil {
+			paidOut = math.Abs(paidOut)
+			header.TotalIn, header.TotalOut = &paidIn, &paidOut
+		}
 	}
 
-	var response []ExtractionResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, nil, err
+	if header == (StatementHeader{}) {
+		return nil
 	}
 
-	var incomes []Income
-	var expenses []Expense
-	for i := range response {
-		incomes = append(incomes, toIncome(response[i])...)
-		expenses = append(expenses, toExpense(response[i])...)
+	return &header
+}
+
+// isMpesaCharge reports whether the expense is a charge row such as "Pay Bill Charge" or
+// "Withdrawal Charge", which M-Pesa lists under the receipt number of the charged transaction.
+func isMpesaCharge(expense Expense) bool {
+	return strings.HasSuffix(strings.ToUpper(strings.TrimSpace(expense.Description)), "CHARGE")
+}
+
+// isMpesaReversal reports whether the details are of a reversal, which M-Pesa lists as a
+// transaction of its own, going the other way, such as "Reversal of QGH4XK2L9P".
+func isMpesaReversal(description string) bool {
+	return strings.Contains(strings.ToUpper(description), "REVERSAL")
+}
+
+// mpesaReversedReceipt returns the receipt number of the transaction the reversal details
+// name, or "" if they name none. The reversal's own receipt number is skipped.
+func mpesaReversedReceipt(description, receipt string) string {
+	for _, match := range mpesaReceiptPattern.FindAllString(strings.ToUpper(description), -1) {
+		if match != strings.TrimSpace(receipt) && strings.ContainsAny(match, "0123456789") &&
+			strings.ContainsAny(match, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+			return match
+		}
 	}
 
-	return incomes, expenses, nil
+	return ""
 }
 
-func toIncome(response ExtractionResponse) []Income {
-	var incomes []Income
+// parseMpesaPage reads the transactions on the page, taking their payment methods from the
+// pack of the statement's network and their categories from the user's pack.
+func parseMpesaPage(response ExtractionResponse, network, pack CountryPack) ParsedStatement {
+	var parsed ParsedStatement
 
+	row := -1
 	for _, tables := range response.Tables {
 		for _, table := range tables {
-			if table.Four == "" || table.Four == "Paid in" || table.Four == "0.00" {
-				continue
-			}
+			row++
 
-			amountStr := strings.ReplaceAll(table.Four, ",", "")
-			amount, err := strconv.ParseFloat(amountStr, 64)
-			if err != nil {
+			if !isMpesaTransactionRow(table) {
 				continue
 			}
 
-			date, err := time.Parse(time.DateTime, table.One)
+			date, err := time.Parse(time.DateTime, strings.TrimSpace(table.One))
 			if err != nil {
+				parsed.reject(response.Page, row, "invalid completion time %q", table.One)
+
 				continue
 			}
 
 			description := strings.TrimSpace(table.Two)
 			if description == "" {
-				return nil
-			}
+				parsed.reject(response.Page, row, "missing details")
 
-			if amount > 0 {
-				income := Income{
-					BaseEntity: BaseEntity{
-						Meta: Metadata{
-							"receiptNo":         table.Zero,
-							"completionTime":    table.One,
-							"transactionStatus": table.Three,
-						},
-					},
-					Date:           Date{date},
-					Source:         extractSource(description),
-					Category:       toCategory(description),
-					Description:    description,
-					PaymentMethod:  toPaymentMethod(description),
-					Amount:         amount,
-					Currency:       "KES",
-					IsRecurring:    isRecurringTransaction(description),
-					OriginalAmount: amount,
-					Status:         Imported,
-				}
-				incomes = append(incomes, income)
+				continue
 			}
-		}
-	}
-
-	return incomes
-}
 
-func toExpense(response ExtractionResponse) []Expense {
-	var expenses []Expense
+			paidIn, err := parseAmount(table.Four)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid paid in amount %q", table.Four)
 
-	for _, tables := range response.Tables {
-		for _, table := range tables {
-			if table.Five == "" || table.Five == "Withdraw\nn" || table.Five == "0.00" {
 				continue
 			}
 
-			amountStr := strings.ReplaceAll(table.Five, ",", "")
-			amount, err := strconv.ParseFloat(amountStr, 64)
+			// Withdrawals are printed as negative amounts on newer statements.
+			withdrawn, err := parseAmount(table.Five)
 			if err != nil {
+				parsed.reject(response.Page, row, "invalid withdrawn amount %q", table.Five)
+
 				continue
 			}
+			withdrawn = math.Abs(withdrawn)
+
+			category, method := pack.Category(description), network.PaymentMethod(description)
+			var meta Metadata
+			switch {
+			case paidIn > 0:
+				parsed.Incomes = append(parsed.Incomes, toIncome(table, date, paidIn, description, category, method))
+				meta = parsed.Incomes[len(parsed.Incomes)-1].Meta
+			case withdrawn > 0:
+				parsed.Expenses = append(parsed.Expenses, toExpense(table, date, withdrawn, description, category, method))
+				meta = parsed.Expenses[len(parsed.Expenses)-1].Meta
+			default:
+				parsed.reject(response.Page, row, "no paid in or withdrawn amount")
 
-			description := strings.TrimSpace(table.Two)
-			if description == "" {
-				return nil
+				continue
 			}
 
-			if amount > 0 {
-				date, err := time.Parse(time.DateTime, table.One)
-				if err != nil {
-					continue
-				}
-
-				expense := Expense{
-					BaseEntity: BaseEntity{
-						Meta: Metadata{
-							"receiptNo":         table.Zero,
-							"completionTime":    table.One,
-							"transactionStatus": table.Three,
-						},
-					},
-					Date:          Date{date},
-					Merchant:      extractMerchant(description),
-					Category:      toCategory(description),
-					Description:   description,
-					PaymentMethod: toPaymentMethod(description),
-					Amount:        amount,
-					Status:        Imported,
-				}
-				expenses = append(expenses, expense)
+			// Rows without a readable balance are left out of the balance history, so the
+			// row after them is flagged as a gap.
+			if balance, err := parseAmount(table.Six); err == nil && strings.TrimSpace(table.Six) != "" {
+				meta["balance"] = balance
+				parsed.balanceRows = append(parsed.balanceRows, balanceRow{
+					page:      response.Page,
+					row:       row,
+					receiptNo: strings.TrimSpace(table.Zero),
+					date:      date,
+					amount:    paidIn - withdrawn,
+					balance:   balance,
+				})
 			}
 		}
 	}
 
-	return expenses
+	return parsed
+}
+
+// isMpesaTransactionRow reports whether the row is a transaction rather than a header,
+// a blank row or a row of the summary table on the first page.
+func isMpesaTransactionRow(table Table) bool {
+	receipt := strings.TrimSpace(table.Zero)
+	if receipt == "" || strings.HasPrefix(strings.ToUpper(receipt), "RECEIPT") {
+		return false
+	}
+
+	return strings.TrimSpace(table.Four) != "" || strings.TrimSpace(table.Five) != ""
+}
+
+func toIncome(
+	table Table, date time.Time, amount float64, description string, category Category, method PaymentMethod,
+) Income {
+	return Income{
+		BaseEntity: BaseEntity{
+			Meta: Metadata{
+				"receiptNo":         table.Zero,
+				"completionTime":    table.One,
+				"transactionStatus": table.Three,
+			},
+		},
+		Date:           Date{date},
+		Source:         extractSource(description),
+		Category:       category,
+		Description:    description,
+		PaymentMethod:  method,
+		Amount:         amount,
+		IsRecurring:    isRecurringTransaction(description),
+		OriginalAmount: amount,
+		Status:         Imported,
+	}
+}
+
+func toExpense(
+	table Table, date time.Time, amount float64, description string, category Category, method PaymentMethod,
+) Expense {
+	return Expense{
+		BaseEntity: BaseEntity{
+			Meta: Metadata{
+				"receiptNo":         table.Zero,
+				"completionTime":    table.One,
+				"transactionStatus": table.Three,
+			},
+		},
+		Date:          Date{date},
+		Merchant:      extractMerchant(description),
+		Category:      category,
+		Description:   description,
+		PaymentMethod: method,
+		Amount:        amount,
+		Status:        Imported,
+	}
 }
 
 func extractSource(description string) string {
@@ -212,107 +324,6 @@ func extractMerchant(description string) string {
 	return "Unknown Merchant"
 }
 
-func toCategory(description string) Category { //nolint:cyclop
-	description = strings.ToUpper(description)
-
-	switch {
-	case strings.Contains(description, "SALARY"), strings.Contains(description, "WAGES"):
-		return SalaryWages
-	case strings.Contains(description, "BUSINESS PAYMENT"), strings.Contains(description, "FREELANCE"),
-		strings.Contains(description, "COMMISSION"), strings.Contains(description, "CONSULTING"):
-		return FreelanceGigWork
-	case strings.Contains(description, "TRANSFER FROM BANK"), strings.Contains(description, "LOAN DISBURSEMENT"):
-		return FreelanceGigWork
-	case strings.Contains(description, "FUNDS RECEIVED"), strings.Contains(description, "GIFT"),
-		strings.Contains(description, "FAMILY"), strings.Contains(description, "RELATIVE"):
-		return GiftsRemittances
-	case strings.Contains(description, "RENT"), strings.Contains(description, "RENTAL"):
-		return RentalIncome
-	case strings.Contains(description, "INTEREST"), strings.Contains(description, "DIVIDEND"):
-		return Interest
-	case strings.Contains(description, "FARM"), strings.Contains(description, "PRODUCE"),
-		strings.Contains(description, "HARVEST"):
-		return FarmProduceSales
-	case strings.Contains(description, "SALE"), strings.Contains(description, "SHOP"),
-		strings.Contains(description, "CUSTOMER"):
-		return BusinessSalesDaily
-
-	case strings.Contains(description, "KPLC"), strings.Contains(description, "ELECTRICITY"),
-		strings.Contains(description, "WATER"), strings.Contains(description, "NAIROBI WATER"),
-		strings.Contains(description, "ELECTRICITY BILL"):
-		return Utilities
-	case strings.Contains(description, "SUPERMARKET"), strings.Contains(description, "GROCERY"),
-		strings.Contains(description, "NAIVAS"), strings.Contains(description, "TUSKYS"),
-		strings.Contains(description, "CARREFOUR"), strings.Contains(description, "QUICKMART"):
-		return Groceries
-	case strings.Contains(description, "UBER"), strings.Contains(description, "BOLT"),
-		strings.Contains(description, "MATATU"), strings.Contains(description, "BUS"),
-		strings.Contains(description, "FUEL"), strings.Contains(description, "PETROL"):
-		return Transport
-	case strings.Contains(description, "AIRTIME"), strings.Contains(description, "DATA"),
-		strings.Contains(description, "SAFARICOM"), strings.Contains(description, "AIRTEL"):
-		return AirtimeData
-	case strings.Contains(description, "RESTAURANT"), strings.Contains(description, "HOTEL"),
-		strings.Contains(description, "CAFE"), strings.Contains(description, "KFC"),
-		strings.Contains(description, "PIZZA"):
-		return FoodDiningOut
-	case strings.Contains(description, "HOSPITAL"), strings.Contains(description, "CLINIC"),
-		strings.Contains(description, "PHARMACY"), strings.Contains(description, "DOCTOR"):
-		return Health
-	case strings.Contains(description, "SCHOOL"), strings.Contains(description, "UNIVERSITY"),
-		strings.Contains(description, "COLLEGE"), strings.Contains(description, "TUITION"):
-		return Education
-	case strings.Contains(description, "CINEMA"), strings.Contains(description, "MOVIE"),
-		strings.Contains(description, "NETFLIX"), strings.Contains(description, "SHOWMAX"):
-		return Entertainment
-	case strings.Contains(description, "CLOTHING"), strings.Contains(description, "FASHION"),
-		strings.Contains(description, "SHOES"):
-		return Clothing
-	case strings.Contains(description, "TITHE"), strings.Contains(description, "OFFERING"),
-		strings.Contains(description, "CHURCH"), strings.Contains(description, "DONATION"):
-		return TitheOfferings
-	case strings.Contains(description, "LOAN"), strings.Contains(description, "CREDIT"),
-		strings.Contains(description, "REPAYMENT"):
-		return LoanRepayment
-	case strings.Contains(description, "SALON"), strings.Contains(description, "BARBER"),
-		strings.Contains(description, "SPA"):
-		return PersonalCare
-	case strings.Contains(description, "SAVINGS"), strings.Contains(description, "INVESTMENT"),
-		strings.Contains(description, "SACCO"):
-		return SavingsInvestment
-	default:
-		return OtherCategory
-	}
-}
-
-func toPaymentMethod(description string) PaymentMethod {
-	description = strings.ToUpper(description)
-
-	switch {
-	case strings.Contains(description, "TRANSFER FROM BANK"), strings.Contains(description, "BANK TRANSFER"):
-		return BankTransfer
-	case strings.Contains(description, "BUSINESS PAYMENT"), strings.Contains(description, "PAYBILL"),
-		strings.Contains(description, "PAY BILL"):
-		return MpesaPaybill
-	case strings.Contains(description, "TILL"), strings.Contains(description, "BUY GOODS"):
-		return MpesaTill
-	case strings.Contains(description, "SEND MONEY"), strings.Contains(description, "SENT TO"),
-		strings.Contains(description, "RECEIVED FROM"):
-		return MpesaSendMoney
-	case strings.Contains(description, "CASH"), strings.Contains(description, "WITHDRAW"),
-		strings.Contains(description, "AGENT"):
-		return Cash
-	case strings.Contains(description, "AIRTEL MONEY"):
-		return AirtelMoney
-	case strings.Contains(description, "EQUITEL"):
-		return EquitelMoney
-	case strings.Contains(description, "T-KASH"):
-		return Tkash
-	default:
-		return MpesaOnline
-	}
-}
-
 func isRecurringTransaction(description string) bool {
 	description = strings.ToUpper(description)
 
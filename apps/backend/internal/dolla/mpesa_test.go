@@ -0,0 +1,451 @@
+package dolla
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMpesaParse(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		fixture string
+		pack    CountryPack
+		golden  string
+	}{
+		{
+			name:    "kenyan statement",
+			fixture: "mpesa.json",
+			pack:    PackFor(Kenya),
+			golden:  "mpesa.golden",
+		},
+		{
+			name:    "categorised with the user's pack",
+			fixture: "mpesa.json",
+			pack:    PackFor(Uganda),
+			golden:  "mpesa_uganda.golden",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := mpesaParser{}.Parse(readPages(t, tc.fixture), tc.pack)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			checkGolden(t, tc.golden, parsed)
+		})
+	}
+}
+
+func TestMpesaParseFees(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		pages []ExtractionResponse
+		// fees are the fees of the expenses, keyed by description.
+		fees map[string]float64
+		// charges are the descriptions of the charges kept as expenses of their own.
+		charges []string
+	}{
+		{
+			name:  "statement",
+			pages: readPages(t, "mpesa.json"),
+			fees: map[string]float64{
+				"Customer Transfer to 0711***222 - MARY ROE":              0,
+				"Merchant Payment Online to 5123456 - NAIVAS SUPERMARKET": 0,
+				"Pay Bill to 888880 - KPLC PREPAID Acc. 12345678901":      15,
+			},
+		},
+		{
+			name: "charge on incoming money",
+			pages: []ExtractionResponse{{Page: 1, Tables: [][]Table{{
+				{
+					Zero: "RCA1B2C3D4", One: "2024-03-02 09:15:00", Two: "Funds received from 0700***001 - JOHN SMITH",
+					Three: "Completed", Four: "5,000.00", Six: "6,000.00",
+				},
+				{
+					Zero: "RCA1B2C3D4", One: "2024-03-02 09:15:00", Two: "Receive Funds Charge",
+					Three: "Completed", Five: "-10.00", Six: "5,990.00",
+				},
+			}}}},
+			fees:    map[string]float64{"Receive Funds Charge": 0},
+			charges: []string{"Receive Funds Charge"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := mpesaParser{}.Parse(tc.pages, PackFor(Kenya))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			fees := make(map[string]float64)
+			var charges []string
+			for i := range parsed.Expenses {
+				expense := parsed.Expenses[i]
+				fees[expense.Description] = expense.Fee
+				if charge, _ := expense.Meta["charge"].(bool); charge {
+					charges = append(charges, expense.Description)
+				}
+			}
+			if !maps.Equal(fees, tc.fees) {
+				t.Errorf("got fees %v, want %v", fees, tc.fees)
+			}
+			if !slices.Equal(charges, tc.charges) {
+				t.Errorf("got charges %v, want %v", charges, tc.charges)
+			}
+		})
+	}
+}
+
+// reversalRow is how a parsed row took part in a reversal.
+type reversalRow struct {
+	status     Status
+	reverses   string
+	reversedBy string
+}
+
+func TestMpesaParseReversals(t *testing.T) {
+	t.Parallel()
+
+	payment := Table{
+		Zero: "RCC3D4E5F6", One: "2024-03-10 18:45:00", Two: "Merchant Payment Online to 5123456 - NAIVAS SUPERMARKET",
+		Three: "Completed", Five: "-1,500.00", Six: "3,485.00",
+	}
+
+	cases := []struct {
+		name  string
+		pages []ExtractionResponse
+		// want is keyed by receipt number.
+		want map[string]reversalRow
+	}{
+		{
+			name:  "statement",
+			pages: readPages(t, "mpesa.json"),
+			want: map[string]reversalRow{
+				"RCA1B2C3D4": {status: Imported},
+				"RCB2C3D4E5": {status: Imported},
+				"RCC3D4E5F6": {status: Canceled, reversedBy: "RCD4E5F6G7"},
+				"RCD4E5F6G7": {status: Canceled, reverses: "RCC3D4E5F6"},
+				"RCF6G7H8J9": {status: Imported},
+			},
+		},
+		{
+			name: "reversal naming no receipt",
+			pages: []ExtractionResponse{{Page: 1, Tables: [][]Table{{
+				{
+					Zero: "RCD4E5F6G7", One: "2024-03-11 08:00:00", Two: "Reversal",
+					Three: "Completed", Four: "1,500.00", Six: "4,985.00",
+				},
+				payment,
+			}}}},
+			want: map[string]reversalRow{
+				"RCC3D4E5F6": {status: Canceled, reversedBy: "RCD4E5F6G7"},
+				"RCD4E5F6G7": {status: Canceled, reverses: "RCC3D4E5F6"},
+			},
+		},
+		{
+			// The original is canceled when the batch is imported.
+			name: "reversal of an earlier statement",
+			pages: []ExtractionResponse{{Page: 1, Tables: [][]Table{{
+				{
+					Zero: "RCD4E5F6G7", One: "2024-03-11 08:00:00", Two: "Reversal of transaction RBZ9Y8X7W6",
+					Three: "Completed", Four: "200.00", Six: "3,685.00",
+				},
+				payment,
+			}}}},
+			want: map[string]reversalRow{
+				"RCC3D4E5F6": {status: Imported},
+				"RCD4E5F6G7": {status: Canceled, reverses: "RBZ9Y8X7W6"},
+			},
+		},
+		{
+			name: "reversal going the same way as the receipt it names",
+			pages: []ExtractionResponse{{Page: 1, Tables: [][]Table{{
+				{
+					Zero: "RCD4E5F6G7", One: "2024-03-11 08:00:00", Two: "Reversal of transaction RCC3D4E5F6",
+					Three: "Completed", Five: "-1,500.00", Six: "1,985.00",
+				},
+				payment,
+			}}}},
+			want: map[string]reversalRow{
+				"RCC3D4E5F6": {status: Imported},
+				"RCD4E5F6G7": {status: Imported},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := mpesaParser{}.Parse(tc.pages, PackFor(Kenya))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			got := make(map[string]reversalRow)
+			add := func(meta Metadata, status Status) {
+				reverses, _ := meta["reverses"].(string)
+				reversedBy, _ := meta["reversedBy"].(string)
+				got[transactionReference(meta)] = reversalRow{status: status, reverses: reverses, reversedBy: reversedBy}
+			}
+			for i := range parsed.Incomes {
+				add(parsed.Incomes[i].Meta, parsed.Incomes[i].Status)
+			}
+			for i := range parsed.Expenses {
+				add(parsed.Expenses[i].Meta, parsed.Expenses[i].Status)
+			}
+
+			if !maps.Equal(got, tc.want) {
+				t.Errorf("got rows %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMpesaParseBalances(t *testing.T) {
+	t.Parallel()
+
+	received := Table{
+		Zero: "RCA1B2C3D4", One: "2024-03-02 09:15:00", Two: "Funds received from 0700***001 - JOHN SMITH",
+		Three: "Completed", Four: "5,000.00", Six: "6,000.00",
+	}
+	paid := Table{
+		Zero: "RCB2C3D4E5", One: "2024-03-05 12:30:10", Two: "Pay Bill to 888880 - KPLC PREPAID",
+		Three: "Completed", Five: "-1,000.00", Six: "5,000.00",
+	}
+	sent := Table{
+		Zero: "RCF6G7H8J9", One: "2024-03-20 14:00:00", Two: "Customer Transfer to 0711***222 - MARY ROE",
+		Three: "Completed", Five: "-65.00", Six: "4,935.00",
+	}
+
+	cases := []struct {
+		name      string
+		pages     []ExtractionResponse
+		balances  StatementBalances
+		snapshots []string
+		gaps      []BalanceGap
+	}{
+		{
+			name:      "statement",
+			pages:     readPages(t, "mpesa.json"),
+			balances:  StatementBalances{Opening: 1000, Closing: 4920},
+			snapshots: []string{"RCA1B2C3D4", "RCB2C3D4E5", "RCB2C3D4E5", "RCC3D4E5F6", "RCD4E5F6G7", "RCF6G7H8J9"},
+		},
+		{
+			name:      "oldest first",
+			pages:     []ExtractionResponse{{Page: 1, Tables: [][]Table{{received, paid, sent}}}},
+			balances:  StatementBalances{Opening: 1000, Closing: 4935},
+			snapshots: []string{"RCA1B2C3D4", "RCB2C3D4E5", "RCF6G7H8J9"},
+		},
+		{
+			name: "wrong balance",
+			pages: []ExtractionResponse{{Page: 2, Tables: [][]Table{{
+				{
+					Zero: "RCF6G7H8J9", One: "2024-03-20 14:00:00", Two: "Customer Transfer to 0711***222 - MARY ROE",
+					Three: "Completed", Five: "-65.00", Six: "4,920.00",
+				},
+				paid,
+				received,
+			}}}},
+			balances:  StatementBalances{Opening: 1000, Closing: 4920},
+			snapshots: []string{"RCA1B2C3D4", "RCB2C3D4E5", "RCF6G7H8J9"},
+			gaps:      []BalanceGap{{Page: 2, Row: 0, ReceiptNo: "RCF6G7H8J9", Expected: 4935, Balance: 4920}},
+		},
+		{
+			name: "row without a balance",
+			pages: []ExtractionResponse{{Page: 1, Tables: [][]Table{{
+				sent,
+				{
+					Zero: "RCB2C3D4E5", One: "2024-03-05 12:30:10", Two: "Pay Bill to 888880 - KPLC PREPAID",
+					Three: "Completed", Five: "-1,000.00",
+				},
+				received,
+			}}}},
+			balances:  StatementBalances{Opening: 1000, Closing: 4935},
+			snapshots: []string{"RCA1B2C3D4", "RCF6G7H8J9"},
+			gaps:      []BalanceGap{{Page: 1, Row: 0, ReceiptNo: "RCF6G7H8J9", Expected: 5935, Balance: 4935}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := mpesaParser{}.Parse(tc.pages, PackFor(Kenya))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if parsed.Balances == nil || *parsed.Balances != tc.balances {
+				t.Errorf("got balances %+v, want %+v", parsed.Balances, tc.balances)
+			}
+			snapshots := make([]string, len(parsed.Snapshots))
+			for i := range parsed.Snapshots {
+				snapshots[i] = parsed.Snapshots[i].ReceiptNo
+			}
+			if !slices.Equal(snapshots, tc.snapshots) {
+				t.Errorf("got snapshots %v, want %v", snapshots, tc.snapshots)
+			}
+			if !slices.Equal(parsed.BalanceGaps, tc.gaps) {
+				t.Errorf("got gaps %+v, want %+v", parsed.BalanceGaps, tc.gaps)
+			}
+		})
+	}
+}
+
+func TestMpesaParseHeader(t *testing.T) {
+	t.Parallel()
+
+	pages := readPages(t, "mpesa.json")
+	withText := func(text string) []ExtractionResponse {
+		edited := slices.Clone(pages)
+		edited[0].Text = text
+
+		return edited
+	}
+	totalIn, totalOut := 6500.0, 2580.0
+	statedIn, statedOut := 6550.0, 2565.0
+	header := StatementHeader{
+		HolderName:   "JANE DOE",
+		MobileNumber: "254700000001",
+		Period: &StatementPeriod{
+			From: Date{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+			To:   Date{time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		},
+		TotalIn:  &totalIn,
+		TotalOut: &totalOut,
+	}
+
+	cases := []struct {
+		name     string
+		pages    []ExtractionResponse
+		header   *StatementHeader
+		warnings Warnings
+	}{
+		{
+			name:   "statement",
+			pages:  pages,
+			header: &header,
+		},
+		{
+			name:  "page range",
+			pages: withText("M-PESA STATEMENT\nPage 1 of 2"),
+		},
+		{
+			name: "totals disagreeing with the transactions",
+			pages: withText("M-PESA STATEMENT\nCustomer Name: JANE DOE\nMobile Number: 254700000001\n" +
+				"Statement Period: 01 Mar 2024 - 31 Mar 2024\nTOTAL: 6,550.00 2,565.00"),
+			header: func() *StatementHeader {
+				header := header
+				header.TotalIn, header.TotalOut = &statedIn, &statedOut
+
+				return &header
+			}(),
+			warnings: Warnings{
+				"statement total paid in is 6550.00 but its transactions add up to 6500.00",
+				"statement total paid out is 2565.00 but its transactions add up to 2580.00",
+			},
+		},
+		{
+			name: "transactions outside the period",
+			pages: withText("M-PESA STATEMENT\nCustomer Name: JANE DOE\n" +
+				"Statement Period: 2024-03-03 - 2024-03-19"),
+			header: &StatementHeader{
+				HolderName: "JANE DOE",
+				Period: &StatementPeriod{
+					From: Date{time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+					To:   Date{time.Date(2024, 3, 19, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+			warnings: Warnings{"transactions outside the statement period 2024-03-03 to 2024-03-19: 2"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := mpesaParser{}.Parse(tc.pages, PackFor(Kenya))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(parsed.Header, tc.header) {
+				t.Errorf("got header %+v, want %+v", parsed.Header, tc.header)
+			}
+			if !slices.Equal(parsed.Warnings, tc.warnings) {
+				t.Errorf("got warnings %q, want %q", parsed.Warnings, tc.warnings)
+			}
+		})
+	}
+}
+
+func TestMpesaParseCurrency(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		parser   StatementParser
+		fixture  string
+		currency string
+	}{
+		{
+			name:     "safaricom statement of a ugandan user",
+			parser:   mpesaParser{},
+			fixture:  "mpesa.json",
+			currency: PackFor(Kenya).Currency,
+		},
+		{
+			name:     "vodacom statement of a ugandan user",
+			parser:   mpesaTanzaniaParser{},
+			fixture:  "mpesa_tanzania.json",
+			currency: PackFor(Tanzania).Currency,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Statements are in the issuer's currency, not the one of the user's pack.
+			parsed, err := tc.parser.Parse(readPages(t, tc.fixture), PackFor(Uganda))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(parsed.Incomes) == 0 {
+				t.Fatal("no incomes read")
+			}
+			for i := range parsed.Incomes {
+				if income := parsed.Incomes[i]; income.Currency != tc.currency {
+					t.Errorf("%s: currency %q, want %q", income.Description, income.Currency, tc.currency)
+				}
+			}
+		})
+	}
+}
+
+func TestMpesaTanzaniaParse(t *testing.T) {
+	t.Parallel()
+
+	// Vodacom statements keep the Tanzanian pack whatever the user's country.
+	parsed, err := mpesaTanzaniaParser{}.Parse(readPages(t, "mpesa_tanzania.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	checkGolden(t, "mpesa_tanzania.golden", parsed)
+}
@@ -0,0 +1,203 @@
+package dolla
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ( //nolint:gochecknoglobals
+	// mt940Tag matches the start of a field such as ":61:" or ":60F:".
+	mt940Tag = regexp.MustCompile(`(?m)^:(\d{2}[A-Z]?):`)
+	// mt940Line splits a :61: statement line into its value date, optional entry date,
+	// debit or credit mark, amount, transaction type, customer reference and bank reference.
+	mt940Line = regexp.MustCompile(
+		`^(\d{6})(\d{4})?(RC|RD|C|D)[A-Z]?(\d+,\d*)([NSF][A-Z0-9]{3})([^/\n]*)(?://([^\n]*))?`,
+	)
+	// mt940Balance splits a balance into its debit or credit mark, date, currency and amount.
+	mt940Balance = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+	// mt940Subfield matches the "?20" style subfield markers of structured :86: fields.
+	mt940Subfield = regexp.MustCompile(`\?\d{2}`)
+)
+
+// mt940Parser reads SWIFT MT940 customer statements. Every statement line of an MT940
+// is booked; pending entries are only sent in MT942 interim reports.
+type mt940Parser struct{}
+
+type mt940Field struct {
+	tag, value string
+}
+
+func (mt940Parser) Name() string {
+	return "MT940 File"
+}
+
+func (mt940Parser) Detect(file []byte) bool {
+	return bytes.Contains(file, []byte(":20:")) && bytes.Contains(file, []byte(":25:")) &&
+		(bytes.Contains(file, []byte(":60F:")) || bytes.Contains(file, []byte(":60M:")))
+}
+
+func (mt940Parser) Parse(file []byte, pack CountryPack) (ParsedStatement, error) {
+	fields := mt940Fields(strings.ReplaceAll(string(file), "\r\n", "\n"))
+	if len(fields) == 0 {
+		return ParsedStatement{}, errors.New("invalid mt940 file: no fields")
+	}
+
+	var parsed ParsedStatement
+	var account, currency string
+	var opening, closing *float64
+	row := 0
+	for i, field := range fields {
+		switch field.tag {
+		case "25":
+			account = field.value
+		case "60F", "60M":
+			amount, ccy, err := parseMT940Balance(field.value)
+			if err != nil {
+				return ParsedStatement{}, err
+			}
+			currency = ccy
+			// The first final opening balance opens the period covered by the file.
+			if opening == nil && field.tag == "60F" {
+				opening = &amount
+			}
+		case "62F":
+			amount, _, err := parseMT940Balance(field.value)
+			if err != nil {
+				return ParsedStatement{}, err
+			}
+			closing = &amount
+		case "61":
+			var info string
+			if i+1 < len(fields) && fields[i+1].tag == "86" {
+				info = fields[i+1].value
+			}
+			parseMT940Line(&parsed, row, field.value, info, account, currency, pack)
+			row++
+		}
+	}
+
+	if opening != nil && closing != nil {
+		parsed.Balances = &StatementBalances{Opening: *opening, Closing: *closing, Currency: currency}
+	}
+
+	return parsed, nil
+}
+
+// mt940Fields splits the messages of an MT940 file into their fields, ignoring the
+// SWIFT block headers and the "-" that ends each message.
+func mt940Fields(text string) []mt940Field {
+	locations := mt940Tag.FindAllStringSubmatchIndex(text, -1)
+
+	fields := make([]mt940Field, 0, len(locations))
+	for i, location := range locations {
+		end := len(text)
+		if i+1 < len(locations) {
+			end = locations[i+1][0]
+		}
+
+		value := strings.TrimSpace(text[location[1]:end])
+		if j := strings.Index(value, "\n-"); j >= 0 {
+			value = value[:j]
+		}
+		fields = append(fields, mt940Field{tag: text[location[2]:location[3]], value: value})
+	}
+
+	return fields
+}
+
+func parseMT940Line(parsed *ParsedStatement, row int, line, info, account, currency string, pack CountryPack) {
+	match := mt940Line.FindStringSubmatch(line)
+	if match == nil {
+		parsed.reject(0, row, "invalid statement line %q", line)
+
+		return
+	}
+
+	date, err := time.Parse("060102", match[1])
+	if err != nil {
+		parsed.reject(0, row, "invalid value date %q", match[1])
+
+		return
+	}
+	// The entry date, when present, is the booking date in the same year as the value date.
+	if match[2] != "" {
+		if booked, err := time.Parse("0102", match[2]); err == nil {
+			date = time.Date(date.Year(), booked.Month(), booked.Day(), 0, 0, 0, 0, time.UTC)
+		}
+	}
+
+	amount, err := parseMT940Amount(match[4])
+	if err != nil || amount == 0 {
+		parsed.reject(0, row, "invalid amount %q", match[4])
+
+		return
+	}
+
+	// A reversed debit (RD) returns money to the account and a reversed credit (RC) takes it away.
+	credit := match[3] == "C" || match[3] == "RD"
+
+	description := normaliseNarration(mt940Subfield.ReplaceAllString(info, " "))
+	if description == "" {
+		description = normaliseNarration(strings.TrimPrefix(line, match[0]))
+	}
+	if description == "" {
+		parsed.reject(0, row, "missing information to account owner")
+
+		return
+	}
+
+	customerRef, bankRef := strings.TrimSpace(match[6]), strings.TrimSpace(match[7])
+	reference := bankRef
+	if reference == "" && customerRef != "NONREF" {
+		reference = customerRef
+	}
+
+	meta := Metadata{
+		"reference":       reference,
+		"customerRef":     customerRef,
+		"bankRef":         bankRef,
+		"transactionType": match[5],
+		"accountId":       account,
+		"valueDate":       match[1],
+		"currency":        currency,
+	}
+
+	var debit, creditAmount float64
+	if credit {
+		creditAmount = amount
+	} else {
+		debit = amount
+	}
+
+	parsed.addBankTransaction(pack, date, description, debit, creditAmount, toBankPaymentMethod(description), meta)
+	if credit {
+		parsed.Incomes[len(parsed.Incomes)-1].Currency = currency
+	}
+}
+
+func parseMT940Balance(value string) (float64, string, error) {
+	match := mt940Balance.FindStringSubmatch(value)
+	if match == nil {
+		return 0, "", fmt.Errorf("invalid mt940 balance: %q", value)
+	}
+
+	amount, err := parseMT940Amount(match[4])
+	if err != nil {
+		return 0, "", err
+	}
+	if match[1] == "D" {
+		amount = -amount
+	}
+
+	return amount, match[3], nil
+}
+
+// parseMT940Amount parses an amount written with a decimal comma, such as "1250,5".
+func parseMT940Amount(value string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+}
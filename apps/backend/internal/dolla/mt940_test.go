@@ -0,0 +1,35 @@
+package dolla
+
+import "testing"
+
+func TestMT940Parse(t *testing.T) {
+	t.Parallel()
+
+	checkFileParser(t, mt940Parser{}, []parserCase{
+		{fixture: "statement.sta", golden: "mt940.golden"},
+	})
+}
+
+func TestMT940ParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := mt940Parser{}.Parse(readFixture(t, "statement.sta"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Structured :86: subfields are joined, and a reversed debit (RD) is a credit.
+	checkRows(t, parsed, map[string]parsedRow{
+		"SALARY MARCH 2024 ACME GMBH": {income: true, amount: 2000, method: BankTransfer},
+		"CITY POWER AG ELECTRICITY":   {amount: 150, method: BankTransfer},
+		"RETURN OF CARD PAYMENT":      {income: true, amount: 20, method: CardDebit},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 0, Row: 3, Reason: `invalid amount "0,00"`},
+	})
+
+	want := StatementBalances{Opening: 1000, Closing: 2870, Currency: "EUR"}
+	if parsed.Balances == nil || *parsed.Balances != want {
+		t.Errorf("got balances %+v, want %+v", parsed.Balances, want)
+	}
+}
@@ -0,0 +1,158 @@
+package dolla
+
+import (
+	"math"
+	"strings"
+)
+
+// MTN MoMo Uganda statements list transactions in seven columns: date, transaction ID,
+// transaction type, the other party, a signed amount that is negative for debits, fee and
+// balance. The other party is printed as "256772123456 - JOHN DOE" or as a name alone.
+var mtnDateLayouts = []string{ //nolint:gochecknoglobals
+	"2006-01-02 15:04:05", "2006-01-02 15:04", "02/01/2006 15:04:05", "02/01/2006 15:04", "02-01-2006 15:04:05",
+}
+
+type mtnUgandaParser struct{}
+
+func (mtnUgandaParser) Name() string {
+	return "MTN MoMo Uganda Statement"
+}
+
+func (mtnUgandaParser) Detect(pages []ExtractionResponse) bool {
+	text := statementText(pages)
+	if !strings.Contains(text, "MTN") ||
+		(!strings.Contains(text, "MOBILE MONEY") && !strings.Contains(text, "MOMO")) {
+		return false
+	}
+
+	return hasHeader(pages, func(table Table) bool {
+		return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(table.Zero)), "DATE") &&
+			strings.EqualFold(strings.TrimSpace(table.One), "Transaction ID")
+	})
+}
+
+// Parse reads the statement with the Ugandan pack whatever the user's country, as MTN Uganda
+// statements are only ever in Ugandan shillings.
+func (mtnUgandaParser) Parse(pages []ExtractionResponse, _ CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseMTNUgandaPage(pages[i]))
+	}
+
+	return parsed, nil
+}
+
+func parseMTNUgandaPage(response ExtractionResponse) ParsedStatement { //nolint:cyclop
+	pack := PackFor(Uganda)
+
+	var parsed ParsedStatement
+	row := -1
+	for _, tables := range response.Tables {
+		for _, table := range tables {
+			row++
+
+			id := strings.TrimSpace(table.One)
+			if id == "" || strings.EqualFold(id, "Transaction ID") || strings.TrimSpace(table.Four) == "" {
+				// Headers, blank rows and wrapped text.
+				continue
+			}
+
+			date, err := parseDate(table.Zero, mtnDateLayouts...)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid transaction date %q", table.Zero)
+
+				continue
+			}
+
+			counterparty := normaliseNarration(table.Three)
+			if _, name, ok := strings.Cut(counterparty, " - "); ok && name != "" {
+				counterparty = name
+			}
+			description := normaliseNarration(table.Two + " " + counterparty)
+			if description == "" {
+				parsed.reject(response.Page, row, "missing transaction type")
+
+				continue
+			}
+			if counterparty == "" {
+				counterparty = strings.ToUpper(description)
+			}
+
+			amount, err := parseAmount(table.Four)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid amount %q", table.Four)
+
+				continue
+			}
+			fee, err := parseAmount(table.Five)
+			if err != nil {
+				parsed.reject(response.Page, row, "invalid fee %q", table.Five)
+
+				continue
+			}
+
+			meta := Metadata{
+				"receiptNo":      id,
+				"completionTime": strings.TrimSpace(table.Zero),
+				"counterparty":   counterparty,
+				"balance":        strings.TrimSpace(table.Six),
+			}
+
+			switch {
+			case amount > 0:
+				parsed.Incomes = append(parsed.Incomes, Income{
+					BaseEntity:     BaseEntity{Meta: meta},
+					Date:           Date{date},
+					Source:         counterparty,
+					Category:       pack.Category(description),
+					Description:    description,
+					PaymentMethod:  pack.PaymentMethod(description),
+					Amount:         amount,
+					Currency:       pack.Currency,
+					IsRecurring:    isRecurringTransaction(description),
+					OriginalAmount: amount,
+					Status:         Imported,
+				})
+			case amount < 0:
+				parsed.Expenses = append(parsed.Expenses, Expense{
+					BaseEntity:    BaseEntity{Meta: meta},
+					Date:          Date{date},
+					Merchant:      counterparty,
+					Category:      pack.Category(description),
+					Description:   description,
+					PaymentMethod: pack.PaymentMethod(description),
+					Amount:        math.Abs(amount),
+					Status:        Imported,
+				})
+			default:
+				parsed.reject(response.Page, row, "no amount")
+
+				continue
+			}
+
+			// Like Airtel Money, MTN charges its fee on the transaction's row.
+			fee = math.Abs(fee)
+			if fee > 0 && amount < 0 {
+				parsed.Expenses[len(parsed.Expenses)-1].Fee = fee
+			} else if fee > 0 {
+				parsed.Expenses = append(parsed.Expenses, Expense{
+					BaseEntity: BaseEntity{Meta: Metadata{
+						"receiptNo":      id,
+						"completionTime": strings.TrimSpace(table.Zero),
+						"feeFor":         description,
+						"charge":         true,
+					}},
+					Date:          Date{date},
+					Merchant:      "MTN MOMO",
+					Category:      OtherCategory,
+					Description:   "MTN MoMo charge",
+					PaymentMethod: MTNMoMo,
+					Amount:        fee,
+					Status:        Imported,
+				})
+			}
+		}
+	}
+
+	return parsed
+}
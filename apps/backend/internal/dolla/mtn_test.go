@@ -0,0 +1,11 @@
+package dolla
+
+import "testing"
+
+func TestMTNUgandaParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, mtnUgandaParser{}, []parserCase{
+		{fixture: "mtn.json", golden: "mtn.golden"},
+	})
+}
@@ -0,0 +1,95 @@
+package dolla
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// NCBA statements list transactions in six columns: transaction date, value date,
+// description, reference, a signed amount that is negative for debits, and balance.
+var ncbaDateLayouts = []string{ //nolint:gochecknoglobals
+	"02/01/2006", "02-Jan-2006", "02 Jan 2006", "02-01-2006", time.DateOnly,
+}
+
+type ncbaParser struct{}
+
+func (ncbaParser) Name() string {
+	return "NCBA Bank Statement"
+}
+
+func (ncbaParser) Detect(pages []ExtractionResponse) bool {
+	title := bankStatementTitle(pages)
+
+	return (strings.Contains(title, "NCBA BANK") || strings.Contains(title, "NCBAGROUP.COM")) &&
+		hasValueDateHeader(pages)
+}
+
+func (ncbaParser) Parse(pages []ExtractionResponse, pack CountryPack) (ParsedStatement, error) {
+	var parsed ParsedStatement
+	for i := range pages {
+		parsed.merge(parseNCBAPage(pages[i], pack))
+	}
+	parsed.issuedIn(Kenya)
+
+	return parsed, nil
+}
+
+func parseNCBAPage(response ExtractionResponse, pack CountryPack) ParsedStatement {
+	var parsed ParsedStatement
+
+	row := -1
+	for _, tables := range response.Tables {
+		for _, table := range tables {
+			row++
+
+			amount, amountErr := parseAmount(table.Four)
+			if amountErr == nil && amount == 0 {
+				// Opening and closing balance rows and wrapped text.
+				continue
+			}
+
+			date, err := parseDate(table.Zero, ncbaDateLayouts...)
+			if err != nil {
+				if amountErr != nil {
+					// Column headers carry neither a date nor an amount.
+					continue
+				}
+				parsed.reject(response.Page, row, "invalid transaction date %q", table.Zero)
+
+				continue
+			}
+
+			description := normaliseNarration(table.Two)
+			if description == "" {
+				parsed.reject(response.Page, row, "missing description")
+
+				continue
+			}
+
+			if amountErr != nil {
+				parsed.reject(response.Page, row, "invalid amount %q", table.Four)
+
+				continue
+			}
+
+			meta := Metadata{
+				"reference":       strings.TrimSpace(table.Three),
+				"transactionDate": strings.TrimSpace(table.Zero),
+				"valueDate":       strings.TrimSpace(table.One),
+				"balance":         strings.TrimSpace(table.Five),
+			}
+
+			var debit, credit float64
+			if amount < 0 {
+				debit = math.Abs(amount)
+			} else {
+				credit = amount
+			}
+
+			parsed.addBankTransaction(pack, date, description, debit, credit, toBankPaymentMethod(description), meta)
+		}
+	}
+
+	return parsed
+}
@@ -0,0 +1,33 @@
+package dolla
+
+import "testing"
+
+func TestNCBAParse(t *testing.T) {
+	t.Parallel()
+
+	checkStatementParser(t, ncbaParser{}, []parserCase{
+		{fixture: "ncba.json", golden: "ncba.golden"},
+	})
+}
+
+func TestNCBAParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := ncbaParser{}.Parse(readPages(t, "ncba.json"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Negative amounts are debits, and standing orders and cheques get their own methods.
+	checkRows(t, parsed, map[string]parsedRow{
+		"SALARY MARCH 2024 ACME LTD":                    {income: true, amount: 85000, method: BankTransfer},
+		"PESALINK TO EQUITY BANK 0123456789 JOHN SMITH": {amount: 5000, method: Pesalink},
+		"POS PURCHASE CARREFOUR SARIT":                  {amount: 3250.5, method: CardDebit},
+		"STANDING ORDER SCHOOL FEES":                    {amount: 15000, method: BankStandingOrd},
+		"CHQ 000789 PAID TO JOHN SMITH":                 {amount: 4000, method: Cheque},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 1, Row: 5, Reason: `invalid transaction date "2024/03/07"`},
+		{Page: 1, Row: 8, Reason: `invalid amount "-1OO.00"`},
+	})
+}
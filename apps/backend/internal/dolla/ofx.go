@@ -0,0 +1,186 @@
+package dolla
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// ofxDateLayouts are the prefixes of OFX dates, which may be followed by
+// milliseconds and a time zone such as "[-5:EST]".
+var ofxDateLayouts = []string{ //nolint:gochecknoglobals
+	"20060102150405", "200601021504", "20060102",
+}
+
+// ofxParser reads OFX files, and the QFX files Quicken downloads, in both the SGML
+// flavour of OFX 1.x, where leaf elements are not closed, and the XML of OFX 2.x.
+type ofxParser struct{}
+
+func (ofxParser) Name() string {
+	return "OFX/QFX File"
+}
+
+func (ofxParser) Detect(file []byte) bool {
+	head := bytes.ToUpper(file[:min(len(file), 1<<10)])
+
+	return bytes.Contains(head, []byte("OFXHEADER")) || bytes.Contains(head, []byte("<OFX>"))
+}
+
+func (ofxParser) Parse(file []byte, pack CountryPack) (ParsedStatement, error) {
+	text := string(file)
+	upper := strings.ToUpper(text)
+	if !strings.Contains(upper, "<OFX>") {
+		return ParsedStatement{}, errors.New("invalid ofx file: missing <OFX> element")
+	}
+
+	// Card statements are wrapped in CCSTMTRS, bank statements in STMTRS.
+	card := strings.Contains(upper, "<CCSTMTRS>")
+	currency := ofxValue(text, "CURDEF")
+	account := ofxValue(text, "ACCTID")
+
+	var parsed ParsedStatement
+	for row, block := range ofxBlocks(text, "STMTTRN") {
+		parseOFXTransaction(&parsed, row, block, account, currency, card, pack)
+	}
+
+	return parsed, nil
+}
+
+func parseOFXTransaction(
+	parsed *ParsedStatement, row int, block, account, currency string, card bool, pack CountryPack,
+) {
+	posted := ofxValue(block, "DTPOSTED")
+	date, err := parseOFXDate(posted)
+	if err != nil {
+		parsed.reject(0, row, "invalid posted date %q", posted)
+
+		return
+	}
+
+	amount, err := parseAmount(ofxValue(block, "TRNAMT"))
+	if err != nil {
+		parsed.reject(0, row, "invalid amount %q", ofxValue(block, "TRNAMT"))
+
+		return
+	}
+	if amount == 0 {
+		parsed.reject(0, row, "no amount")
+
+		return
+	}
+
+	name, memo := ofxValue(block, "NAME"), ofxValue(block, "MEMO")
+	description := name
+	if memo != "" && !strings.EqualFold(memo, name) {
+		description = strings.TrimSpace(name + " " + memo)
+	}
+	description = normaliseNarration(description)
+	if description == "" {
+		parsed.reject(0, row, "missing name and memo")
+
+		return
+	}
+	if card && amount > 0 && (ofxValue(block, "TRNTYPE") == "PAYMENT" || isCardPayment(description)) {
+		// Payments towards the card settle its balance; they are not income, unlike refunds.
+		return
+	}
+
+	fitid := ofxValue(block, "FITID")
+	meta := Metadata{
+		"fitid":           fitid,
+		"accountId":       account,
+		"transactionType": ofxValue(block, "TRNTYPE"),
+		"currency":        currency,
+	}
+	if checkNo := ofxValue(block, "CHECKNUM"); checkNo != "" {
+		meta["chequeNo"] = checkNo
+	}
+
+	method := CardCredit
+	if !card {
+		method = toOFXPaymentMethod(ofxValue(block, "TRNTYPE"), description)
+	}
+
+	var debit, credit float64
+	if amount < 0 {
+		debit = math.Abs(amount)
+	} else {
+		credit = amount
+	}
+
+	parsed.addBankTransaction(pack, date, description, debit, credit, method, meta)
+	if credit > 0 {
+		parsed.Incomes[len(parsed.Incomes)-1].Currency = currency
+	}
+}
+
+// ofxBlocks returns the contents of every element with the given name.
+func ofxBlocks(text, name string) []string {
+	upper := strings.ToUpper(text)
+	open, end := "<"+name+">", "</"+name+">"
+
+	var blocks []string
+	for {
+		start := strings.Index(upper, open)
+		if start < 0 {
+			return blocks
+		}
+		start += len(open)
+
+		stop := strings.Index(upper[start:], end)
+		if stop < 0 {
+			blocks = append(blocks, text[start:])
+
+			return blocks
+		}
+		blocks = append(blocks, text[start:start+stop])
+
+		text, upper = text[start+stop+len(end):], upper[start+stop+len(end):]
+	}
+}
+
+// ofxValue returns the value of the first leaf element with the given name. The value
+// runs up to the next tag, which covers both closed XML and unclosed SGML elements.
+func ofxValue(text, name string) string {
+	open := "<" + name + ">"
+	start := strings.Index(strings.ToUpper(text), open)
+	if start < 0 {
+		return ""
+	}
+	value := text[start+len(open):]
+	if end := strings.IndexByte(value, '<'); end >= 0 {
+		value = value[:end]
+	}
+
+	return strings.TrimSpace(unescapeOFX(value))
+}
+
+func unescapeOFX(value string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&apos;", "'", "&quot;", `"`).Replace(value)
+}
+
+func parseOFXDate(value string) (time.Time, error) {
+	// Drop milliseconds and the time zone, keeping the date and time as printed.
+	if i := strings.IndexAny(value, ".["); i >= 0 {
+		value = value[:i]
+	}
+
+	return parseDate(value, ofxDateLayouts...)
+}
+
+func toOFXPaymentMethod(transactionType, description string) PaymentMethod {
+	switch strings.ToUpper(transactionType) {
+	case "CHECK":
+		return Cheque
+	case "ATM", "CASH":
+		return Cash
+	case "POS":
+		return CardDebit
+	case "DIRECTDEBIT", "REPEATPMT":
+		return BankStandingOrd
+	default:
+		return toBankPaymentMethod(description)
+	}
+}
@@ -0,0 +1,61 @@
+package dolla
+
+import "testing"
+
+func TestOFXParse(t *testing.T) {
+	t.Parallel()
+
+	checkFileParser(t, ofxParser{}, []parserCase{
+		{fixture: "bank.ofx", golden: "bank_ofx.golden"},
+		{fixture: "card.qfx", golden: "card_qfx.golden"},
+	})
+}
+
+func TestOFXParseRows(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		fixture  string
+		rows     map[string]parsedRow
+		rejected []RejectedRow
+	}{
+		{
+			fixture: "bank.ofx",
+			rows: map[string]parsedRow{
+				"ACME CORP PAYROLL SALARY MARCH": {income: true, amount: 2500, method: BankTransfer},
+				"CHECK 1042":                     {amount: 120, method: Cheque},
+				"CORNER GROCERY & DELI":          {amount: 45.3, method: CardDebit},
+			},
+			rejected: []RejectedRow{{Page: 0, Row: 3, Reason: `invalid posted date "2024-03-09"`}},
+		},
+		{
+			// Payments towards the card settle its balance, they are not income, while refunds are.
+			fixture: "card.qfx",
+			rows: map[string]parsedRow{
+				"ONLINE BOOKSTORE":                       {amount: 64.99, method: CardCredit},
+				"ONLINE BOOKSTORE REFUND":                {income: true, amount: 20, method: CardCredit},
+				"STREAMING SERVICE MONTHLY SUBSCRIPTION": {amount: 12.5, method: CardCredit},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := ofxParser{}.Parse(readFixture(t, tc.fixture), PackFor(Kenya))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			checkRows(t, parsed, tc.rows)
+			checkRejected(t, parsed, tc.rejected)
+			// Incomes keep the currency the file states rather than the user's.
+			for i := range parsed.Incomes {
+				if parsed.Incomes[i].Currency != "USD" {
+					t.Errorf("%s has currency %q, want USD", parsed.Incomes[i].Description, parsed.Incomes[i].Currency)
+				}
+			}
+		})
+	}
+}
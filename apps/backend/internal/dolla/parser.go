@@ -0,0 +1,281 @@
+package dolla
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// headerPages is the number of leading pages inspected when fingerprinting a statement.
+const headerPages = 2
+
+// ErrUnsupportedStatement is returned for a statement type no parser is registered for.
+var ErrUnsupportedStatement = errors.New("unsupported statement type")
+
+// UndetectedStatementError is returned when a statement does not match exactly one parser.
+type UndetectedStatementError struct {
+	Candidates []Statement
+}
+
+func (e *UndetectedStatementError) Error() string {
+	candidates := make([]string, len(e.Candidates))
+	for i := range e.Candidates {
+		candidates[i] = string(e.Candidates[i])
+	}
+
+	return fmt.Sprintf("unable to detect statement type, candidates: %s", strings.Join(candidates, ", "))
+}
+
+// ParserRegistry maps each supported statement type to the parser that handles it.
+// PDF statements are read by a StatementParser and structured files by a FileParser.
+type ParserRegistry struct {
+	parsers map[Statement]StatementParser
+	files   map[Statement]FileParser
+}
+
+func NewParserRegistry() *ParserRegistry {
+	return &ParserRegistry{
+		parsers: make(map[Statement]StatementParser),
+		files:   make(map[Statement]FileParser),
+	}
+}
+
+// DefaultParsers returns a registry with every statement parser shipped with dolla.
+func DefaultParsers() *ParserRegistry {
+	registry := NewParserRegistry()
+	registry.Register(MpesaStatement, mpesaParser{})
+	registry.Register(IMBankStatement, imBankParser{})
+	registry.Register(EquityStatement, equityParser{})
+	registry.Register(KCBStatement, kcbParser{})
+	registry.Register(NCBAStatement, ncbaParser{})
+	registry.Register(CoopStatement, coopParser{})
+	registry.Register(AbsaStatement, absaParser{})
+	registry.Register(AbsaCardStatement, absaCardParser{})
+	registry.Register(AirtelStatement, airtelParser{})
+	registry.Register(MTNUgandaStatement, mtnUgandaParser{})
+	registry.Register(MpesaTanzaniaStatement, mpesaTanzaniaParser{})
+	registry.RegisterFile(OFXStatement, ofxParser{})
+	registry.RegisterFile(QIFStatement, qifParser{})
+	registry.RegisterFile(CAMT053Statement, camt053Parser{})
+	registry.RegisterFile(MT940Statement, mt940Parser{})
+
+	return registry
+}
+
+// Register adds a parser for the given statement type, replacing any existing one.
+func (r *ParserRegistry) Register(ttype Statement, parser StatementParser) {
+	r.parsers[ttype] = parser
+}
+
+// RegisterFile adds a parser for the given structured file type, replacing any existing one.
+func (r *ParserRegistry) RegisterFile(ttype Statement, parser FileParser) {
+	r.files[ttype] = parser
+}
+
+func (r *ParserRegistry) Get(ttype Statement) (StatementParser, bool) {
+	parser, ok := r.parsers[ttype]
+
+	return parser, ok
+}
+
+func (r *ParserRegistry) GetFile(ttype Statement) (FileParser, bool) {
+	parser, ok := r.files[ttype]
+
+	return parser, ok
+}
+
+// Supports reports whether a PDF or a file parser is registered for the statement type.
+func (r *ParserRegistry) Supports(ttype Statement) bool {
+	_, pdf := r.parsers[ttype]
+	_, file := r.files[ttype]
+
+	return pdf || file
+}
+
+// DetectFile returns the structured file type whose parser recognises the file.
+// It reports false for files no file parser recognises, such as PDF statements.
+func (r *ParserRegistry) DetectFile(file []byte) (Statement, bool) {
+	for ttype, parser := range r.files {
+		if parser.Detect(file) {
+			return ttype, true
+		}
+	}
+
+	return "", false
+}
+
+// Detect returns the statement type whose parser recognises the pages.
+// It fails with an UndetectedStatementError when no parser, or more than one, matches.
+func (r *ParserRegistry) Detect(pages []ExtractionResponse) (Statement, error) {
+	var matches []Statement
+	for ttype, parser := range r.parsers {
+		if parser.Detect(pages) {
+			matches = append(matches, ttype)
+		}
+	}
+
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+
+	if len(matches) == 0 {
+		for ttype := range r.parsers {
+			matches = append(matches, ttype)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i] < matches[j]
+	})
+
+	return "", &UndetectedStatementError{Candidates: matches}
+}
+
+// StatementTypes lists the registered statement types sorted by type.
+func (r *ParserRegistry) StatementTypes() []StatementType {
+	types := make([]StatementType, 0, len(r.parsers)+len(r.files))
+	for ttype, parser := range r.parsers {
+		types = append(types, StatementType{
+			Type: ttype,
+			Name: parser.Name(),
+		})
+	}
+	for ttype, parser := range r.files {
+		types = append(types, StatementType{
+			Type: ttype,
+			Name: parser.Name(),
+		})
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Type < types[j].Type
+	})
+
+	return types
+}
+
+func (p *ParsedStatement) reject(page uint64, row int, format string, args ...any) {
+	p.Rejected = append(p.Rejected, RejectedRow{
+		Page:   page,
+		Row:    row,
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+// issuedIn sets the currency of the incomes to that of the country the statement was issued
+// in, for statements that are only ever in their country's currency and do not state it.
+func (p *ParsedStatement) issuedIn(country Country) {
+	currency := PackFor(country).Currency
+	for i := range p.Incomes {
+		p.Incomes[i].Currency = currency
+	}
+}
+
+func (p *ParsedStatement) merge(other ParsedStatement) {
+	p.Incomes = append(p.Incomes, other.Incomes...)
+	p.Expenses = append(p.Expenses, other.Expenses...)
+	p.Rejected = append(p.Rejected, other.Rejected...)
+	p.balanceRows = append(p.balanceRows, other.balanceRows...)
+}
+
+// linkFees records each charge expense as the fee of the expense it was charged on, which
+// shares its receipt number and completion time. Charges without such an expense, such as
+// those on incoming money, are kept and marked as charges.
+func (p *ParsedStatement) linkFees(isCharge func(expense Expense) bool) {
+	feeKey := func(meta Metadata) string {
+		return strings.TrimSpace(fmt.Sprint(meta["receiptNo"])) + "|" +
+			strings.TrimSpace(fmt.Sprint(meta["completionTime"]))
+	}
+
+	parents := make(map[string]int)
+	for i := range p.Expenses {
+		if !isCharge(p.Expenses[i]) {
+			if _, ok := parents[feeKey(p.Expenses[i].Meta)]; !ok {
+				parents[feeKey(p.Expenses[i].Meta)] = i
+			}
+		}
+	}
+
+	linked := make(map[int]bool)
+	for i := range p.Expenses {
+		if !isCharge(p.Expenses[i]) {
+			continue
+		}
+		parent, ok := parents[feeKey(p.Expenses[i].Meta)]
+		if !ok {
+			if p.Expenses[i].Meta != nil {
+				p.Expenses[i].Meta["charge"] = true
+			}
+
+			continue
+		}
+		p.Expenses[parent].Fee += p.Expenses[i].Amount
+		p.Expenses[parent].Meta["feeDescription"] = p.Expenses[i].Description
+		linked[i] = true
+	}
+
+	expenses := make([]Expense, 0, len(p.Expenses)-len(linked))
+	for i := range p.Expenses {
+		if !linked[i] {
+			expenses = append(expenses, p.Expenses[i])
+		}
+	}
+	p.Expenses = expenses
+}
+
+// period returns the dates of the earliest and latest transactions, or nil if there are none.
+func (p *ParsedStatement) period() *StatementPeriod {
+	var period *StatementPeriod
+	include := func(date Date) {
+		switch {
+		case period == nil:
+			period = &StatementPeriod{From: date, To: date}
+		case date.Before(period.From.Time):
+			period.From = date
+		case date.After(period.To.Time):
+			period.To = date
+		}
+	}
+
+	for i := range p.Incomes {
+		include(p.Incomes[i].Date)
+	}
+	for i := range p.Expenses {
+		include(p.Expenses[i].Date)
+	}
+
+	return period
+}
+
+// statementText returns the upper-cased text of the first pages of a statement,
+// where issuers print their name and the statement title.
+func statementText(pages []ExtractionResponse) string {
+	var text strings.Builder
+	for i := range pages {
+		if i == headerPages {
+			break
+		}
+		text.WriteString(strings.ToUpper(pages[i].Text))
+		text.WriteString("\n")
+	}
+
+	return text.String()
+}
+
+// hasHeader reports whether any table row on the first pages satisfies match.
+func hasHeader(pages []ExtractionResponse, match func(table Table) bool) bool {
+	for i := range pages {
+		if i == headerPages {
+			break
+		}
+		for _, tables := range pages[i].Tables {
+			for _, table := range tables {
+				if match(table) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
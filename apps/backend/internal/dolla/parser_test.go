@@ -0,0 +1,81 @@
+package dolla
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParserRegistryDetect(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		fixture string
+		want    Statement
+	}{
+		{fixture: "mpesa.json", want: MpesaStatement},
+		{fixture: "mpesa_tanzania.json", want: MpesaTanzaniaStatement},
+		{fixture: "ncba.json", want: NCBAStatement},
+		{fixture: "coop.json", want: CoopStatement},
+		{fixture: "equity.json", want: EquityStatement},
+		{fixture: "kcb.json", want: KCBStatement},
+		{fixture: "imbank.json", want: IMBankStatement},
+		{fixture: "absa.json", want: AbsaStatement},
+		{fixture: "absa_card.json", want: AbsaCardStatement},
+		{fixture: "airtel.json", want: AirtelStatement},
+		{fixture: "mtn.json", want: MTNUgandaStatement},
+	}
+
+	registry := DefaultParsers()
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := registry.Detect(readPages(t, tc.fixture))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParserRegistryDetectUndetected(t *testing.T) {
+	t.Parallel()
+
+	_, err := DefaultParsers().Detect(readPages(t, "unknown.json"))
+
+	var undetected *UndetectedStatementError
+	if !errors.As(err, &undetected) {
+		t.Fatalf("got %v, want an UndetectedStatementError", err)
+	}
+	checkGolden(t, "unknown.golden", undetected.Candidates)
+}
+
+func TestParserRegistryDetectFile(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		fixture string
+		want    Statement
+	}{
+		{fixture: "bank.ofx", want: OFXStatement},
+		{fixture: "card.qfx", want: OFXStatement},
+		{fixture: "bank.qif", want: QIFStatement},
+		{fixture: "camt053.xml", want: CAMT053Statement},
+		{fixture: "statement.sta", want: MT940Statement},
+	}
+
+	registry := DefaultParsers()
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := registry.DetectFile(readFixture(t, tc.fixture))
+			if !ok || got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
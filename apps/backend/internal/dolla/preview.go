@@ -0,0 +1,228 @@
+package dolla
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// previewTTL is how long a preview can be committed after it was made.
+const previewTTL = 30 * time.Minute
+
+var (
+	// ErrPreviewNotFound is returned when committing a preview that expired, was already
+	// committed or belongs to another user.
+	ErrPreviewNotFound = errors.New("preview not found or expired")
+	// ErrInvalidCategoryEdit is returned when a commit edits a transaction the preview does not
+	// have or sets a category that does not exist.
+	ErrInvalidCategoryEdit = errors.New("invalid category edit")
+)
+
+// storedPreview is a parsed statement kept between its preview and commit, so that the
+// client can only change categories and never what else is saved.
+type storedPreview struct {
+	userID    string
+	statement Statement
+	fileName  string
+	fileHash  string
+	parsed    ParsedStatement
+	expires   time.Time
+}
+
+func (s *service) PreviewTransaction(
+	ctx context.Context, userID string, ttype Statement, fileHeader *multipart.FileHeader, password string,
+) (ImportPreview, error) {
+	if ttype != "" {
+		if !s.parsers.Supports(ttype) {
+			return ImportPreview{}, fmt.Errorf("%w: %s", ErrUnsupportedStatement, ttype)
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return ImportPreview{}, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return ImportPreview{}, err
+	}
+
+	pack := s.countryPack(ctx, userID)
+	var parsed ParsedStatement
+	if fileType, parser, ok := s.fileParser(ttype, data); ok {
+		ttype = fileType
+		if parsed, err = parser.Parse(data, pack); err != nil {
+			return ImportPreview{}, err
+		}
+	} else {
+		var pages []ExtractionResponse
+		if pages, err = s.extractor.Extract(ctx, bytes.NewReader(data), password); err != nil {
+			return ImportPreview{}, err
+		}
+
+		if ttype, parsed, err = s.parseStatement(ttype, pages, pack); err != nil {
+			return ImportPreview{}, err
+		}
+	}
+
+	pack.localise(&parsed)
+	stampTransactions(userID, parsed.Incomes, parsed.Expenses)
+	duplicates, err := s.repo.FindDuplicates(ctx, userID, parsed.Incomes, parsed.Expenses)
+	if err != nil {
+		return ImportPreview{}, err
+	}
+
+	duplicateKeys := make(map[string]bool)
+	for i := range duplicates.Incomes {
+		duplicateKeys["income:"+duplicates.Incomes[i].DedupeKey] = true
+	}
+	for i := range duplicates.Expenses {
+		duplicateKeys["expense:"+duplicates.Expenses[i].DedupeKey] = true
+	}
+
+	preview := ImportPreview{
+		Token:       uuid.NewString(),
+		Statement:   ttype,
+		FileName:    fileHeader.Filename,
+		Incomes:     make([]PreviewIncome, len(parsed.Incomes)),
+		Expenses:    make([]PreviewExpense, len(parsed.Expenses)),
+		Rejected:    parsed.Rejected,
+		Period:      parsed.period(),
+		Balances:    parsed.Balances,
+		Snapshots:   parsed.Snapshots,
+		BalanceGaps: parsed.BalanceGaps,
+		Header:      parsed.Header,
+		Warnings:    parsed.Warnings,
+	}
+	for i := range parsed.Incomes {
+		preview.Incomes[i] = PreviewIncome{
+			Income:    parsed.Incomes[i],
+			Duplicate: duplicateKeys["income:"+parsed.Incomes[i].DedupeKey],
+		}
+	}
+	for i := range parsed.Expenses {
+		preview.Expenses[i] = PreviewExpense{
+			Expense:   parsed.Expenses[i],
+			Duplicate: duplicateKeys["expense:"+parsed.Expenses[i].DedupeKey],
+		}
+	}
+	if preview.Rejected == nil {
+		preview.Rejected = []RejectedRow{}
+	}
+
+	s.storePreview(preview.Token, storedPreview{
+		userID:    userID,
+		statement: ttype,
+		fileName:  fileHeader.Filename,
+		fileHash:  hashFile(data),
+		parsed:    parsed,
+		expires:   time.Now().Add(previewTTL),
+	})
+
+	return preview, nil
+}
+
+func (s *service) CommitTransaction(ctx context.Context, userID string, commit ImportCommit) (ImportJob, error) {
+	preview, err := s.takePreview(userID, commit.Token)
+	if err != nil {
+		return ImportJob{}, err
+	}
+	// Edit copies of the transactions, so that the stored preview is left as it was if the
+	// edits are refused.
+	parsed := preview.parsed
+	parsed.Incomes, parsed.Expenses = slices.Clone(parsed.Incomes), slices.Clone(parsed.Expenses)
+	if err := applyCategoryEdits(&parsed, commit); err != nil {
+		// Let the user fix the edits and commit again.
+		s.previews.Store(commit.Token, preview)
+
+		return ImportJob{}, err
+	}
+
+	// The job has no file to run again from, so it is not resumable.
+	job := ImportJob{
+		UserID:    userID,
+		Statement: preview.statement,
+		FileName:  preview.fileName,
+		Status:    ImportSaving,
+	}
+	job.PopulateDataOnCreate(ctx)
+	s.queued.Store(job.ID, struct{}{})
+	defer s.queued.Delete(job.ID)
+	if err := s.repo.CreateImportJob(ctx, job); err != nil {
+		return ImportJob{}, err
+	}
+
+	result, err := s.importTransactions(ctx, userID, job.ID, parsed)
+	if err != nil {
+		s.failImport(ctx, &job, err)
+
+		return ImportJob{}, err
+	}
+	s.recordDocument(ctx, &job, preview.fileHash, parsed)
+
+	job.Result = &result
+	s.setImportStatus(ctx, &job, ImportDone)
+
+	return job, nil
+}
+
+// storePreview keeps the preview under its token, dropping the previews that expired.
+func (s *service) storePreview(token string, preview storedPreview) {
+	now := time.Now()
+	s.previews.Range(func(key, value any) bool {
+		if stored, ok := value.(storedPreview); ok && now.After(stored.expires) {
+			s.previews.Delete(key)
+		}
+
+		return true
+	})
+
+	s.previews.Store(token, preview)
+}
+
+// takePreview removes and returns the user's preview stored under the token.
+func (s *service) takePreview(userID, token string) (storedPreview, error) {
+	value, ok := s.previews.LoadAndDelete(token)
+	if !ok {
+		return storedPreview{}, ErrPreviewNotFound
+	}
+
+	preview, ok := value.(storedPreview)
+	if !ok || preview.userID != userID || time.Now().After(preview.expires) {
+		if ok && preview.userID != userID {
+			// Another user's token leaves their preview in place.
+			s.previews.Store(token, preview)
+		}
+
+		return storedPreview{}, ErrPreviewNotFound
+	}
+
+	return preview, nil
+}
+
+// applyCategoryEdits sets the categories the user chose on the previewed transactions.
+func applyCategoryEdits(parsed *ParsedStatement, commit ImportCommit) error {
+	for _, edit := range commit.Incomes {
+		if edit.Index < 0 || edit.Index >= len(parsed.Incomes) || !edit.Category.known() {
+			return fmt.Errorf("%w: income %d", ErrInvalidCategoryEdit, edit.Index)
+		}
+		parsed.Incomes[edit.Index].Category = edit.Category
+	}
+	for _, edit := range commit.Expenses {
+		if edit.Index < 0 || edit.Index >= len(parsed.Expenses) || !edit.Category.known() {
+			return fmt.Errorf("%w: expense %d", ErrInvalidCategoryEdit, edit.Index)
+		}
+		parsed.Expenses[edit.Index].Category = edit.Category
+	}
+
+	return nil
+}
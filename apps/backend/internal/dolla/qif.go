@@ -0,0 +1,165 @@
+package dolla
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// QIF dates are month first. Quicken writes years from 2000 after an apostrophe,
+// e.g. "1/ 2'24", which parseQIFDate normalises to "1/2/24".
+var qifDateLayouts = []string{ //nolint:gochecknoglobals
+	"1/2/2006", "1/2/06", "1-2-2006", "1-2-06", time.DateOnly,
+}
+
+// qifParser reads QIF files of bank, cash and credit card accounts. Each transaction
+// is a run of lines starting with a field code and ends with a "^" line.
+type qifParser struct{}
+
+// qifTransaction holds the fields of a QIF transaction that are imported.
+type qifTransaction struct {
+	date, amount, payee, memo, number, category string
+}
+
+func (qifParser) Name() string {
+	return "QIF File"
+}
+
+func (qifParser) Detect(file []byte) bool {
+	file = bytes.TrimPrefix(file, []byte("\ufeff"))
+
+	return bytes.HasPrefix(bytes.TrimSpace(file), []byte("!Type:"))
+}
+
+func (qifParser) Parse(file []byte, pack CountryPack) (ParsedStatement, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(file, []byte("\ufeff"))))
+
+	var parsed ParsedStatement
+	var account string
+	var current qifTransaction
+	row := 0
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		code, value := line[0], strings.TrimSpace(line[1:])
+		switch code {
+		case '!':
+			if strings.HasPrefix(strings.ToUpper(line), "!TYPE:") {
+				account = strings.ToUpper(strings.TrimSpace(line[len("!Type:"):]))
+			}
+		case '^':
+			parseQIFTransaction(&parsed, row, current, account, pack)
+			current = qifTransaction{}
+			row++
+		case 'D':
+			current.date = value
+		case 'T', 'U':
+			current.amount = value
+		case 'P':
+			current.payee = value
+		case 'M':
+			current.memo = value
+		case 'N':
+			current.number = value
+		case 'L':
+			current.category = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ParsedStatement{}, err
+	}
+	if account == "" {
+		return ParsedStatement{}, errors.New("invalid qif file: missing !Type header")
+	}
+
+	return parsed, nil
+}
+
+func parseQIFTransaction(parsed *ParsedStatement, row int, txn qifTransaction, account string, pack CountryPack) {
+	date, err := parseQIFDate(txn.date)
+	if err != nil {
+		parsed.reject(0, row, "invalid date %q", txn.date)
+
+		return
+	}
+
+	amount, err := parseAmount(txn.amount)
+	if err != nil {
+		parsed.reject(0, row, "invalid amount %q", txn.amount)
+
+		return
+	}
+	if amount == 0 {
+		parsed.reject(0, row, "no amount")
+
+		return
+	}
+
+	card := account == "CCARD"
+
+	description := txn.payee
+	if txn.memo != "" && !strings.EqualFold(txn.memo, txn.payee) {
+		description = strings.TrimSpace(txn.payee + " " + txn.memo)
+	}
+	description = normaliseNarration(description)
+	if description == "" {
+		parsed.reject(0, row, "missing payee and memo")
+
+		return
+	}
+	if card && amount > 0 && isCardPayment(description) {
+		// Payments towards the card settle its balance; they are not income, unlike refunds.
+		return
+	}
+
+	meta := Metadata{
+		"qifDate": txn.date,
+	}
+	if txn.category != "" {
+		meta["qifCategory"] = txn.category
+	}
+
+	var method PaymentMethod
+	switch {
+	case card:
+		method = CardCredit
+	case account == "CASH":
+		method = Cash
+	// Number fields hold cheque numbers, but also markers such as "ATM", "DEP" or "EFT".
+	case isDigits(txn.number):
+		meta["chequeNo"] = txn.number
+		method = Cheque
+	default:
+		method = toBankPaymentMethod(description)
+	}
+
+	var debit, credit float64
+	if amount < 0 {
+		debit = math.Abs(amount)
+	} else {
+		credit = amount
+	}
+
+	// QIF files do not state their currency, the user's country pack fills it in.
+	parsed.addBankTransaction(pack, date, description, debit, credit, method, meta)
+}
+
+func parseQIFDate(value string) (time.Time, error) {
+	value = strings.ReplaceAll(value, " ", "")
+	value = strings.ReplaceAll(value, "'", "/")
+
+	return parseDate(value, qifDateLayouts...)
+}
+
+// isDigits reports whether the value is a number such as a cheque or agent number.
+func isDigits(value string) bool {
+	return value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return r < '0' || r > '9'
+	}) == -1
+}
@@ -0,0 +1,54 @@
+package dolla
+
+import "testing"
+
+func TestQIFParse(t *testing.T) {
+	t.Parallel()
+
+	checkFileParser(t, qifParser{}, []parserCase{
+		{fixture: "bank.qif", golden: "bank_qif.golden"},
+		{fixture: "card.qif", golden: "card_qif.golden"},
+	})
+}
+
+func TestQIFParseRows(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := qifParser{}.Parse(readFixture(t, "bank.qif"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Numeric number fields are cheque numbers, while markers such as "ATM" are not.
+	checkRows(t, parsed, map[string]parsedRow{
+		"ACME CORP PAYROLL SALARY MARCH": {income: true, amount: 2500, method: BankTransfer},
+		"LANDLORD":                       {amount: 120, method: Cheque},
+		"ATM WITHDRAWAL":                 {amount: 45.3, method: Cash},
+	})
+	checkRejected(t, parsed, []RejectedRow{
+		{Page: 0, Row: 3, Reason: `invalid date "13/40/2024"`},
+		{Page: 0, Row: 4, Reason: "no amount"},
+	})
+	// QIF files do not state their currency, so it is left for the user's pack.
+	for i := range parsed.Incomes {
+		if parsed.Incomes[i].Currency != "" {
+			t.Errorf("%s has currency %q, want none", parsed.Incomes[i].Description, parsed.Incomes[i].Currency)
+		}
+	}
+}
+
+func TestQIFParseCard(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := qifParser{}.Parse(readFixture(t, "card.qif"), PackFor(Kenya))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Payments towards the card settle its balance, they are not income, while refunds are.
+	checkRows(t, parsed, map[string]parsedRow{
+		"ONLINE BOOKSTORE":        {amount: 64.99, method: CardCredit},
+		"ONLINE BOOKSTORE REFUND": {income: true, amount: 20, method: CardCredit},
+	})
+	checkRejected(t, parsed, nil)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
@@ -29,7 +30,10 @@ const (
 		description TEXT,
 		payment_method VARCHAR(255),
 		amount REAL,
-		status VARCHAR(255)
+		status VARCHAR(255),
+		dedupe_key VARCHAR(255) NOT NULL DEFAULT '',
+		import_id VARCHAR(255) NOT NULL DEFAULT '',
+		fee REAL NOT NULL DEFAULT 0
 	);
 
 	CREATE TABLE IF NOT EXISTS incomes (
@@ -50,7 +54,9 @@ const (
 		currency VARCHAR(255),
 		is_recurring BOOLEAN DEFAULT FALSE,
 		original_amount REAL,
-		status VARCHAR(255)
+		status VARCHAR(255),
+		dedupe_key VARCHAR(255) NOT NULL DEFAULT '',
+		import_id VARCHAR(255) NOT NULL DEFAULT ''
 	);
 
 	CREATE TABLE IF NOT EXISTS user_profiles (
@@ -66,7 +72,8 @@ const (
 		life_stage VARCHAR(50) NOT NULL,
 		income_bracket VARCHAR(50) NOT NULL,
 		goals TEXT NOT NULL,
-		onboarding_complete BOOLEAN DEFAULT FALSE
+		onboarding_complete BOOLEAN DEFAULT FALSE,
+		country VARCHAR(2) NOT NULL DEFAULT 'KE'
 	);
 
 	CREATE TABLE IF NOT EXISTS budgets (
@@ -87,8 +94,138 @@ const (
 		is_overspent BOOLEAN DEFAULT FALSE,
 		UNIQUE(user_id, month, category)
 	);
+
+	CREATE TABLE IF NOT EXISTS import_jobs (
+		id UUID PRIMARY KEY,
+		date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_by VARCHAR(255),
+		date_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_by VARCHAR(255),
+		active BOOLEAN DEFAULT TRUE,
+		meta JSONB DEFAULT '{}',
+		user_id VARCHAR(255) NOT NULL,
+		statement VARCHAR(255) NOT NULL DEFAULT '',
+		file_name VARCHAR(1024) NOT NULL DEFAULT '',
+		status VARCHAR(255) NOT NULL,
+		error_message TEXT NOT NULL DEFAULT '',
+		error_code VARCHAR(255) NOT NULL DEFAULT '',
+		candidates JSONB,
+		result JSONB,
+		resumable BOOLEAN NOT NULL DEFAULT FALSE,
+		file BLOB
+	);
+
+	CREATE TABLE IF NOT EXISTS csv_mappings (
+		id UUID PRIMARY KEY,
+		date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_by VARCHAR(255),
+		date_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_by VARCHAR(255),
+		active BOOLEAN DEFAULT TRUE,
+		meta JSONB DEFAULT '{}',
+		user_id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		mapping JSONB NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS mpesa_shortcodes (
+		id UUID PRIMARY KEY,
+		date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_by VARCHAR(255),
+		date_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_by VARCHAR(255),
+		active BOOLEAN DEFAULT TRUE,
+		meta JSONB DEFAULT '{}',
+		user_id VARCHAR(255) NOT NULL,
+		shortcode VARCHAR(20) NOT NULL,
+		payment_method VARCHAR(50) NOT NULL,
+		secret_hash VARCHAR(64) NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS balance_snapshots (
+		id UUID PRIMARY KEY,
+		date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_by VARCHAR(255),
+		date_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_by VARCHAR(255),
+		active BOOLEAN DEFAULT TRUE,
+		meta JSONB DEFAULT '{}',
+		user_id VARCHAR(255) NOT NULL,
+		account VARCHAR(255) NOT NULL,
+		date TIMESTAMP NOT NULL,
+		balance REAL NOT NULL,
+		receipt_no VARCHAR(255) NOT NULL DEFAULT '',
+		import_id VARCHAR(255) NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS statement_documents (
+		id UUID PRIMARY KEY,
+		date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_by VARCHAR(255),
+		date_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_by VARCHAR(255),
+		active BOOLEAN DEFAULT TRUE,
+		meta JSONB DEFAULT '{}',
+		user_id VARCHAR(255) NOT NULL,
+		import_id VARCHAR(255) NOT NULL,
+		statement VARCHAR(255) NOT NULL DEFAULT '',
+		parser VARCHAR(255) NOT NULL DEFAULT '',
+		file_name VARCHAR(1024) NOT NULL DEFAULT '',
+		file_hash VARCHAR(64) NOT NULL,
+		holder_name VARCHAR(1024) NOT NULL DEFAULT '',
+		mobile_number VARCHAR(20) NOT NULL DEFAULT '',
+		period_from VARCHAR(10),
+		period_to VARCHAR(10),
+		opening_balance REAL,
+		closing_balance REAL,
+		total_in REAL,
+		total_out REAL,
+		warnings JSONB NOT NULL DEFAULT '[]'
+	);
+	`
+
+	// indexSQL runs after the migrations since it depends on migrated columns.
+	// Only imported transactions have a dedupe key, so manual entries are not constrained.
+	indexSQL = `
+	CREATE UNIQUE INDEX IF NOT EXISTS incomes_user_dedupe_key
+		ON incomes (user_id, dedupe_key) WHERE dedupe_key != '';
+
+	CREATE UNIQUE INDEX IF NOT EXISTS expenses_user_dedupe_key
+		ON expenses (user_id, dedupe_key) WHERE dedupe_key != '';
+
+	CREATE INDEX IF NOT EXISTS incomes_user_import_id ON incomes (user_id, import_id);
+
+	CREATE INDEX IF NOT EXISTS expenses_user_import_id ON expenses (user_id, import_id);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS balance_snapshots_user_row
+		ON balance_snapshots (user_id, account, date, receipt_no, balance);
+
+	CREATE INDEX IF NOT EXISTS statement_documents_user_import_id ON statement_documents (user_id, import_id);
 	`
 
+	insertIncomeSQL = `INSERT INTO incomes
+		(id, date_created, created_by, date_updated, updated_by, active, meta,
+		user_id, date, source, category, description, payment_method, amount, currency,
+		is_recurring, original_amount, status, dedupe_key, import_id)
+		VALUES (:id, :date_created, :created_by, :date_updated, :updated_by,
+		:active, :meta, :user_id, :date, :source, :category, :description, :payment_method,
+		:amount, :currency, :is_recurring, :original_amount, :status, :dedupe_key, :import_id)`
+
+	insertExpenseSQL = `INSERT INTO expenses
+		(id, date_created, created_by, date_updated, updated_by, active, meta,
+		user_id, date, merchant, category, description, payment_method, amount, fee, status, dedupe_key, import_id)
+		VALUES (:id, :date_created, :created_by, :date_updated, :updated_by,
+		:active, :meta, :user_id, :date, :merchant, :category, :description, :payment_method,
+		:amount, :fee, :status, :dedupe_key, :import_id)`
+
+	// importJobColumns leaves out the uploaded file, which is only read by the workers.
+	importJobColumns = `id, date_created, created_by, date_updated, updated_by, active, meta,
+		user_id, statement, file_name, status, error_message, error_code, candidates, result, resumable`
+
+	// busyTimeout makes concurrent writers, such as the import workers, wait for the
+	// database lock instead of failing with SQLITE_BUSY.
+	busyTimeout = "_pragma=busy_timeout(5000)"
+
 	percent           = 100.0
 	maxBudgets uint64 = 1000
 )
@@ -98,7 +235,12 @@ type sqlite3 struct {
 }
 
 func NewRepository(file string) (dolla.Repository, error) {
-	db, err := sqlx.Open("sqlite", file)
+	separator := "?"
+	if strings.Contains(file, "?") {
+		separator = "&"
+	}
+
+	db, err := sqlx.Open("sqlite", file+separator+busyTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -108,13 +250,775 @@ func NewRepository(file string) (dolla.Repository, error) {
 		return nil, err
 	}
 
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(indexSQL)
+	if err != nil {
+		return nil, err
+	}
+
 	return &sqlite3{
 		db: db,
 	}, nil
 }
 
-func (r *sqlite3) CreateIncome(ctx context.Context, incomes ...dolla.Income) error {
-	if len(incomes) == 0 {
+// migrate adds columns introduced after a table was first provisioned.
+// Databases created from provisionSQL already have them, so duplicate columns are ignored.
+func migrate(db *sqlx.DB) error {
+	migrations := []string{
+		`ALTER TABLE incomes ADD COLUMN dedupe_key VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE expenses ADD COLUMN dedupe_key VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE incomes ADD COLUMN import_id VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE expenses ADD COLUMN import_id VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE user_profiles ADD COLUMN country VARCHAR(2) NOT NULL DEFAULT 'KE'`,
+		`ALTER TABLE expenses ADD COLUMN fee REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE import_jobs ADD COLUMN error_code VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE import_jobs ADD COLUMN candidates JSONB`,
+		`ALTER TABLE import_jobs ADD COLUMN resumable BOOLEAN NOT NULL DEFAULT FALSE`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *sqlite3) CreateIncome(ctx context.Context, incomes ...dolla.Income) error {
+	if len(incomes) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for i := range incomes {
+		if _, err := tx.NamedExecContext(ctx, insertIncomeSQL, incomes[i]); err != nil {
+			if err := tx.Rollback(); err != nil {
+				slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+			}
+
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *sqlite3) GetIncome(ctx context.Context, userID, id string) (dolla.Income, error) {
+	query := `SELECT * FROM incomes WHERE id = $1 AND user_id = $2`
+	rows, err := r.db.QueryxContext(ctx, query, id, userID)
+	if err != nil {
+		return dolla.Income{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	if rows.Next() {
+		var income dolla.Income
+		if err := rows.StructScan(&income); err != nil {
+			return dolla.Income{}, err
+		}
+
+		return income, nil
+	}
+
+	return dolla.Income{}, errors.New("not found")
+}
+
+func (r *sqlite3) ListIncomes(ctx context.Context, userID string, query dolla.Query) (dolla.IncomePage, error) {
+	q := fmt.Sprintf(`SELECT * FROM incomes WHERE user_id = $1 LIMIT %d OFFSET %d`, query.Limit, query.Offset)
+	rows, err := r.db.QueryxContext(ctx, q, userID)
+	if err != nil {
+		return dolla.IncomePage{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	incomes := make([]dolla.Income, 0)
+	for rows.Next() {
+		var income dolla.Income
+		if err := rows.StructScan(&income); err != nil {
+			return dolla.IncomePage{}, err
+		}
+
+		incomes = append(incomes, income)
+	}
+
+	tq := `SELECT COUNT(*) FROM incomes WHERE user_id = $1`
+	rows, err = r.db.QueryxContext(ctx, tq, userID)
+	if err != nil {
+		return dolla.IncomePage{}, err
+	}
+
+	total := uint64(0)
+	if rows.Next() {
+		if err := rows.Scan(&total); err != nil {
+			return dolla.IncomePage{}, err
+		}
+	}
+
+	return dolla.IncomePage{
+		Offset:  query.Offset,
+		Limit:   query.Limit,
+		Total:   total,
+		Incomes: incomes,
+	}, nil
+}
+
+func (r *sqlite3) UpdateIncome(ctx context.Context, income dolla.Income) error { //nolint:cyclop
+	queries := []string{
+		`date_updated = :date_updated`,
+	}
+	if !income.Date.Time.IsZero() {
+		queries = append(queries, `date = :date`)
+	}
+	if income.Source != "" {
+		queries = append(queries, `source = :source`)
+	}
+	if income.Category != "" {
+		queries = append(queries, `category = :category`)
+	}
+	if income.Description != "" {
+		queries = append(queries, `description = :description`)
+	}
+	if income.PaymentMethod != "" {
+		queries = append(queries, `payment_method = :payment_method`)
+	}
+	if income.Amount != 0 {
+		queries = append(queries, `amount = :amount`)
+	}
+	if income.Currency != "" {
+		queries = append(queries, `currency = :currency`)
+	}
+	if income.IsRecurring {
+		queries = append(queries, `is_recurring = :is_recurring`)
+	}
+	if income.OriginalAmount != 0 {
+		queries = append(queries, `original_amount = :original_amount`)
+	}
+	if income.Status != "" {
+		queries = append(queries, `status = :status`)
+	}
+
+	query := fmt.Sprintf(`UPDATE incomes SET %s WHERE id = :id`, strings.Join(queries, ", "))
+	if _, err := r.db.NamedExecContext(ctx, query, income); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *sqlite3) DeleteIncome(ctx context.Context, userID, id string) error {
+	query := `DELETE FROM incomes WHERE id = $1 AND user_id = $2`
+	if _, err := r.db.ExecContext(ctx, query, id, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *sqlite3) CreateExpense(ctx context.Context, expenses ...dolla.Expense) error {
+	if len(expenses) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for i := range expenses {
+		if _, err := tx.NamedExecContext(ctx, insertExpenseSQL, expenses[i]); err != nil {
+			if err := tx.Rollback(); err != nil {
+				slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+			}
+
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *sqlite3) GetExpense(ctx context.Context, userID, id string) (dolla.Expense, error) {
+	query := `SELECT * FROM expenses WHERE id = $1 AND user_id = $2`
+	rows, err := r.db.QueryxContext(ctx, query, id, userID)
+	if err != nil {
+		return dolla.Expense{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	if rows.Next() {
+		var expense dolla.Expense
+		if err := rows.StructScan(&expense); err != nil {
+			return dolla.Expense{}, err
+		}
+
+		return expense, nil
+	}
+
+	return dolla.Expense{}, errors.New("not found")
+}
+
+func (r *sqlite3) ListExpenses(ctx context.Context, userID string, query dolla.Query) (dolla.ExpensePage, error) {
+	q := fmt.Sprintf(`SELECT * FROM expenses WHERE user_id = $1 LIMIT %d OFFSET %d`, query.Limit, query.Offset)
+	rows, err := r.db.QueryxContext(ctx, q, userID)
+	if err != nil {
+		return dolla.ExpensePage{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	expenses := make([]dolla.Expense, 0)
+	for rows.Next() {
+		var expense dolla.Expense
+		if err := rows.StructScan(&expense); err != nil {
+			return dolla.ExpensePage{}, err
+		}
+
+		expenses = append(expenses, expense)
+	}
+
+	tq := `SELECT COUNT(*) FROM expenses WHERE user_id = $1`
+	rows, err = r.db.QueryxContext(ctx, tq, userID)
+	if err != nil {
+		return dolla.ExpensePage{}, err
+	}
+
+	total := uint64(0)
+	if rows.Next() {
+		if err := rows.Scan(&total); err != nil {
+			return dolla.ExpensePage{}, err
+		}
+	}
+
+	return dolla.ExpensePage{
+		Offset:   query.Offset,
+		Limit:    query.Limit,
+		Total:    total,
+		Expenses: expenses,
+	}, nil
+}
+
+func (r *sqlite3) UpdateExpense(ctx context.Context, expense dolla.Expense) error {
+	queries := []string{
+		`date_updated = :date_updated`,
+	}
+	if !expense.Date.Time.IsZero() {
+		queries = append(queries, `date = :date`)
+	}
+	if expense.Merchant != "" {
+		queries = append(queries, `merchant = :merchant`)
+	}
+	if expense.Category != "" {
+		queries = append(queries, `category = :category`)
+	}
+	if expense.Description != "" {
+		queries = append(queries, `description = :description`)
+	}
+	if expense.PaymentMethod != "" {
+		queries = append(queries, `payment_method = :payment_method`)
+	}
+	if expense.Amount != 0 {
+		queries = append(queries, `amount = :amount`)
+	}
+	if expense.Fee != 0 {
+		queries = append(queries, `fee = :fee`)
+	}
+	if expense.Status != "" {
+		queries = append(queries, `status = :status`)
+	}
+
+	query := fmt.Sprintf(`UPDATE expenses SET %s WHERE id = :id`, strings.Join(queries, ", "))
+	if _, err := r.db.NamedExecContext(ctx, query, expense); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *sqlite3) DeleteExpense(ctx context.Context, userID, id string) error {
+	query := `DELETE FROM expenses WHERE id = $1 AND user_id = $2`
+	if _, err := r.db.ExecContext(ctx, query, id, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *sqlite3) ImportTransactions( //nolint:cyclop
+	ctx context.Context, batch dolla.ImportBatch,
+) (dolla.ImportedBatch, error) {
+	imported := dolla.ImportedBatch{
+		Duplicates: dolla.Duplicates{
+			Incomes:  make([]dolla.Income, 0),
+			Expenses: make([]dolla.Expense, 0),
+		},
+	}
+	if len(batch.Incomes) == 0 && len(batch.Expenses) == 0 {
+		return imported, nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return dolla.ImportedBatch{}, err
+	}
+
+	rollback := func() {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
+	}
+
+	skipped := make(map[string]bool)
+	for i := range batch.Incomes {
+		inserted, err := insertOrSkip(ctx, tx, insertIncomeSQL, batch.Incomes[i])
+		if err != nil {
+			rollback()
+
+			return dolla.ImportedBatch{}, err
+		}
+		if !inserted {
+			imported.Duplicates.Incomes = append(imported.Duplicates.Incomes, batch.Incomes[i])
+			skipped["incomes|"+batch.Incomes[i].DedupeKey] = true
+		}
+	}
+
+	for i := range batch.Expenses {
+		inserted, err := insertOrSkip(ctx, tx, insertExpenseSQL, batch.Expenses[i])
+		if err != nil {
+			rollback()
+
+			return dolla.ImportedBatch{}, err
+		}
+		if !inserted {
+			imported.Duplicates.Expenses = append(imported.Duplicates.Expenses, batch.Expenses[i])
+			skipped["expenses|"+batch.Expenses[i].DedupeKey] = true
+		}
+	}
+
+	months := make(map[string]bool)
+	for _, reversal := range batch.Reversals {
+		table := "expenses"
+		if reversal.Income {
+			table = "incomes"
+		}
+		if skipped[table+"|"+reversal.DedupeKey] {
+			// The reversal was imported before, together with its cancellation.
+			continue
+		}
+
+		canceled, err := cancelReversed(ctx, tx, reversal, table)
+		if err != nil {
+			rollback()
+
+			return dolla.ImportedBatch{}, err
+		}
+		for _, month := range canceled {
+			months[month] = true
+		}
+	}
+	for month := range months {
+		imported.Months = append(imported.Months, month)
+	}
+	sort.Strings(imported.Months)
+
+	if err := tx.Commit(); err != nil {
+		rollback()
+
+		return dolla.ImportedBatch{}, err
+	}
+
+	return imported, nil
+}
+
+// cancelReversed cancels the user's transaction with the reversed receipt number, keeping
+// its status and the receipt of the reversal in its metadata so that deleting the reversal's
+// import restores it. A reversal whose transaction the user does not have is imported as is.
+// It returns the months of the canceled expenses.
+func cancelReversed(ctx context.Context, tx *sqlx.Tx, reversal dolla.Reversal, table string) ([]string, error) {
+	var months []string
+	monthsQuery := `SELECT DISTINCT strftime('%Y-%m', date) FROM expenses
+		WHERE user_id = $1 AND active = true AND json_extract(CAST(meta AS TEXT), '$.receiptNo') = $2`
+	if err := tx.SelectContext(ctx, &months, monthsQuery, reversal.UserID, reversal.Receipt); err != nil {
+		return nil, err
+	}
+
+	// Metadata is stored as a JSON blob, which the JSON functions read and write as text.
+	var found bool
+	for _, reversed := range []string{"incomes", "expenses"} {
+		query := `
+			UPDATE ` + reversed + `
+			SET status = $1,
+			    meta = CAST(json_set(COALESCE(CAST(meta AS TEXT), '{}'),
+			        '$.reversedBy', $2, '$.reversedStatus', COALESCE(status, $3)) AS BLOB),
+			    date_updated = CURRENT_TIMESTAMP
+			WHERE user_id = $4
+			  AND active = true
+			  AND json_extract(CAST(meta AS TEXT), '$.receiptNo') = $5
+		`
+		result, err := tx.ExecContext(
+			ctx, query, dolla.Canceled, reversal.ReversedBy, dolla.Imported, reversal.UserID, reversal.Receipt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		found = found || affected > 0
+	}
+	if found {
+		return months, nil
+	}
+
+	query := `
+		UPDATE ` + table + `
+		SET status = $1, meta = CAST(json_remove(CAST(meta AS TEXT), '$.reverses') AS BLOB)
+		WHERE user_id = $2 AND dedupe_key = $3
+	`
+	if _, err := tx.ExecContext(ctx, query, dolla.Imported, reversal.UserID, reversal.DedupeKey); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (r *sqlite3) FindDuplicates(
+	ctx context.Context, userID string, incomes []dolla.Income, expenses []dolla.Expense,
+) (dolla.Duplicates, error) {
+	duplicates := dolla.Duplicates{
+		Incomes:  make([]dolla.Income, 0),
+		Expenses: make([]dolla.Expense, 0),
+	}
+
+	keys := make([]string, len(incomes))
+	for i := range incomes {
+		keys[i] = incomes[i].DedupeKey
+	}
+	existing, err := r.existingDedupeKeys(ctx, "incomes", userID, keys)
+	if err != nil {
+		return dolla.Duplicates{}, err
+	}
+	for i := range incomes {
+		if existing[incomes[i].DedupeKey] {
+			duplicates.Incomes = append(duplicates.Incomes, incomes[i])
+		}
+	}
+
+	keys = make([]string, len(expenses))
+	for i := range expenses {
+		keys[i] = expenses[i].DedupeKey
+	}
+	existing, err = r.existingDedupeKeys(ctx, "expenses", userID, keys)
+	if err != nil {
+		return dolla.Duplicates{}, err
+	}
+	for i := range expenses {
+		if existing[expenses[i].DedupeKey] {
+			duplicates.Expenses = append(duplicates.Expenses, expenses[i])
+		}
+	}
+
+	return duplicates, nil
+}
+
+// existingDedupeKeys returns which of the keys the user already has in the table.
+func (r *sqlite3) existingDedupeKeys(
+	ctx context.Context, table, userID string, keys []string,
+) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(keys) == 0 {
+		return existing, nil
+	}
+
+	query, args, err := sqlx.In(
+		`SELECT dedupe_key FROM `+table+` WHERE user_id = ? AND dedupe_key IN (?)`, userID, keys,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		existing[key] = true
+	}
+
+	return existing, rows.Err()
+}
+
+// insertOrSkip runs the insert, ignoring rows that violate the dedupe key index,
+// and reports whether the row was inserted.
+func insertOrSkip(ctx context.Context, tx *sqlx.Tx, query string, arg any) (bool, error) {
+	res, err := tx.NamedExecContext(ctx, query+` ON CONFLICT DO NOTHING`, arg)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *sqlite3) CreateImportJob(ctx context.Context, job dolla.ImportJob) error {
+	query := `INSERT INTO import_jobs
+		(id, date_created, created_by, date_updated, updated_by, active, meta,
+		user_id, statement, file_name, status, error_message, error_code, candidates, result, resumable, file)
+		VALUES (:id, :date_created, :created_by, :date_updated, :updated_by, :active, :meta, :user_id,
+		:statement, :file_name, :status, :error_message, :error_code, :candidates, :result, :resumable, :file)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, job); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *sqlite3) GetImportJob(ctx context.Context, userID, id string) (dolla.ImportJob, error) {
+	query := `SELECT ` + importJobColumns + ` FROM import_jobs WHERE id = $1 AND user_id = $2 AND active = true`
+	rows, err := r.db.QueryxContext(ctx, query, id, userID)
+	if err != nil {
+		return dolla.ImportJob{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	if rows.Next() {
+		var job dolla.ImportJob
+		if err := rows.StructScan(&job); err != nil {
+			return dolla.ImportJob{}, err
+		}
+
+		return job, nil
+	}
+
+	return dolla.ImportJob{}, dolla.ErrImportNotFound
+}
+
+func (r *sqlite3) GetImportJobFile(ctx context.Context, id string) ([]byte, error) {
+	var file []byte
+	query := `SELECT file FROM import_jobs WHERE id = $1 AND file IS NOT NULL`
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&file); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (r *sqlite3) ListImportJobs(ctx context.Context, userID string, query dolla.Query) (dolla.ImportJobPage, error) {
+	q := fmt.Sprintf(
+		`SELECT %s FROM import_jobs WHERE user_id = $1 AND active = true ORDER BY date_created DESC LIMIT %d OFFSET %d`,
+		importJobColumns, query.Limit, query.Offset,
+	)
+	rows, err := r.db.QueryxContext(ctx, q, userID)
+	if err != nil {
+		return dolla.ImportJobPage{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	jobs := make([]dolla.ImportJob, 0)
+	for rows.Next() {
+		var job dolla.ImportJob
+		if err := rows.StructScan(&job); err != nil {
+			return dolla.ImportJobPage{}, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	var total uint64
+	tq := `SELECT COUNT(*) FROM import_jobs WHERE user_id = $1 AND active = true`
+	if err := r.db.QueryRowContext(ctx, tq, userID).Scan(&total); err != nil {
+		return dolla.ImportJobPage{}, err
+	}
+
+	return dolla.ImportJobPage{
+		Offset:  query.Offset,
+		Limit:   query.Limit,
+		Total:   total,
+		Imports: jobs,
+	}, nil
+}
+
+func (r *sqlite3) ListPendingImportJobs(ctx context.Context) ([]dolla.ImportJob, error) {
+	query := `SELECT ` + importJobColumns + ` FROM import_jobs WHERE status NOT IN ($1, $2) ORDER BY date_created`
+	rows, err := r.db.QueryxContext(ctx, query, dolla.ImportDone, dolla.ImportFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	jobs := make([]dolla.ImportJob, 0)
+	for rows.Next() {
+		var job dolla.ImportJob
+		if err := rows.StructScan(&job); err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func (r *sqlite3) UpdateImportJob(ctx context.Context, job dolla.ImportJob) error {
+	queries := []string{
+		`date_updated = :date_updated`,
+		`statement = :statement`,
+		`status = :status`,
+		`error_message = :error_message`,
+		`error_code = :error_code`,
+		`candidates = :candidates`,
+		`result = :result`,
+	}
+	if job.Status == dolla.ImportDone || job.Status == dolla.ImportFailed {
+		queries = append(queries, `file = NULL`)
+	}
+
+	query := fmt.Sprintf(`UPDATE import_jobs SET %s WHERE id = :id`, strings.Join(queries, ", "))
+	if _, err := r.db.NamedExecContext(ctx, query, job); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *sqlite3) DeleteImport(ctx context.Context, userID, id string) ([]string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback := func() {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
+	}
+
+	// The receipts of the import's reversals, by which it marked the earlier transactions it canceled.
+	reversals := `
+		SELECT json_extract(CAST(meta AS TEXT), '$.receiptNo') FROM incomes
+		WHERE user_id = $1 AND import_id = $2 AND json_extract(CAST(meta AS TEXT), '$.reverses') IS NOT NULL
+		UNION
+		SELECT json_extract(CAST(meta AS TEXT), '$.receiptNo') FROM expenses
+		WHERE user_id = $1 AND import_id = $2 AND json_extract(CAST(meta AS TEXT), '$.reverses') IS NOT NULL`
+
+	var months []string
+	monthsQuery := `SELECT DISTINCT strftime('%Y-%m', date) FROM expenses WHERE user_id = $1
+		AND (import_id = $2 OR json_extract(CAST(meta AS TEXT), '$.reversedBy') IN (` + reversals + `))`
+	if err := tx.SelectContext(ctx, &months, monthsQuery, userID, id); err != nil {
+		rollback()
+
+		return nil, err
+	}
+
+	for _, table := range []string{"incomes", "expenses"} {
+		query := `
+			UPDATE ` + table + `
+			SET status = COALESCE(json_extract(CAST(meta AS TEXT), '$.reversedStatus'), $3),
+			    meta = CAST(json_remove(CAST(meta AS TEXT), '$.reversedBy', '$.reversedStatus') AS BLOB),
+			    date_updated = CURRENT_TIMESTAMP
+			WHERE user_id = $1
+			  AND import_id != $2
+			  AND json_extract(CAST(meta AS TEXT), '$.reversedBy') IN (` + reversals + `)
+		`
+		if _, err := tx.ExecContext(ctx, query, userID, id, dolla.Imported); err != nil {
+			rollback()
+
+			return nil, err
+		}
+	}
+
+	queries := []string{
+		`DELETE FROM incomes WHERE user_id = $1 AND import_id = $2`,
+		`DELETE FROM expenses WHERE user_id = $1 AND import_id = $2`,
+		`DELETE FROM balance_snapshots WHERE user_id = $1 AND import_id = $2`,
+		`UPDATE import_jobs SET active = false, date_updated = CURRENT_TIMESTAMP WHERE user_id = $1 AND id = $2`,
+		`UPDATE statement_documents SET active = false, date_updated = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND import_id = $2`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, userID, id); err != nil {
+			rollback()
+
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		rollback()
+
+		return nil, err
+	}
+
+	return months, nil
+}
+
+func (r *sqlite3) CreateBalanceSnapshots(ctx context.Context, snapshots []dolla.BalanceSnapshot) error {
+	if len(snapshots) == 0 {
 		return nil
 	}
 
@@ -123,16 +1027,15 @@ func (r *sqlite3) CreateIncome(ctx context.Context, incomes ...dolla.Income) err
 		return err
 	}
 
-	for i := range incomes {
-		query := `INSERT INTO incomes
+	query := `INSERT INTO balance_snapshots
 		(id, date_created, created_by, date_updated, updated_by, active, meta,
-		user_id, date, source, category, description, payment_method, amount, currency,
-		is_recurring, original_amount, status)
+		user_id, account, date, balance, receipt_no, import_id)
 		VALUES (:id, :date_created, :created_by, :date_updated, :updated_by,
-		:active, :meta, :user_id, :date, :source, :category, :description, :payment_method,
-		:amount, :currency, :is_recurring, :original_amount, :status)`
+		:active, :meta, :user_id, :account, :date, :balance, :receipt_no, :import_id)`
 
-		if _, err := tx.NamedExecContext(ctx, query, incomes[i]); err != nil {
+	// Overlapping statements print the same rows, which are kept once.
+	for i := range snapshots {
+		if _, err := insertOrSkip(ctx, tx, query, snapshots[i]); err != nil {
 			if err := tx.Rollback(); err != nil {
 				slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
 			}
@@ -152,11 +1055,15 @@ func (r *sqlite3) CreateIncome(ctx context.Context, incomes ...dolla.Income) err
 	return nil
 }
 
-func (r *sqlite3) GetIncome(ctx context.Context, userID, id string) (dolla.Income, error) {
-	query := `SELECT * FROM incomes WHERE id = $1 AND user_id = $2`
-	rows, err := r.db.QueryxContext(ctx, query, id, userID)
+func (r *sqlite3) ListBalanceSnapshots(
+	ctx context.Context, userID string, account dolla.Statement,
+) ([]dolla.BalanceSnapshot, error) {
+	query := `SELECT * FROM balance_snapshots
+		WHERE user_id = $1 AND account = $2 AND active = true
+		ORDER BY date, rowid`
+	rows, err := r.db.QueryxContext(ctx, query, userID, account)
 	if err != nil {
-		return dolla.Income{}, err
+		return nil, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -164,23 +1071,41 @@ func (r *sqlite3) GetIncome(ctx context.Context, userID, id string) (dolla.Incom
 		}
 	}()
 
-	if rows.Next() {
-		var income dolla.Income
-		if err := rows.StructScan(&income); err != nil {
-			return dolla.Income{}, err
+	snapshots := make([]dolla.BalanceSnapshot, 0)
+	for rows.Next() {
+		var snapshot dolla.BalanceSnapshot
+		if err := rows.StructScan(&snapshot); err != nil {
+			return nil, err
 		}
+		snapshots = append(snapshots, snapshot)
+	}
 
-		return income, nil
+	return snapshots, nil
+}
+
+func (r *sqlite3) CreateStatementDocument(ctx context.Context, document dolla.StatementDocument) error {
+	query := `INSERT INTO statement_documents
+		(id, date_created, created_by, date_updated, updated_by, active, meta,
+		user_id, import_id, statement, parser, file_name, file_hash, holder_name, mobile_number,
+		period_from, period_to, opening_balance, closing_balance, total_in, total_out, warnings)
+		VALUES (:id, :date_created, :created_by, :date_updated, :updated_by, :active, :meta,
+		:user_id, :import_id, :statement, :parser, :file_name, :file_hash, :holder_name, :mobile_number,
+		:period_from, :period_to, :opening_balance, :closing_balance, :total_in, :total_out, :warnings)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, document); err != nil {
+		return err
 	}
 
-	return dolla.Income{}, errors.New("not found")
+	return nil
 }
 
-func (r *sqlite3) ListIncomes(ctx context.Context, userID string, query dolla.Query) (dolla.IncomePage, error) {
-	q := fmt.Sprintf(`SELECT * FROM incomes WHERE user_id = $1 LIMIT %d OFFSET %d`, query.Limit, query.Offset)
-	rows, err := r.db.QueryxContext(ctx, q, userID)
+func (r *sqlite3) GetStatementDocument(
+	ctx context.Context, userID, importID string,
+) (dolla.StatementDocument, error) {
+	query := `SELECT * FROM statement_documents WHERE user_id = $1 AND import_id = $2 AND active = true`
+	rows, err := r.db.QueryxContext(ctx, query, userID, importID)
 	if err != nil {
-		return dolla.IncomePage{}, err
+		return dolla.StatementDocument{}, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -188,82 +1113,106 @@ func (r *sqlite3) ListIncomes(ctx context.Context, userID string, query dolla.Qu
 		}
 	}()
 
-	incomes := make([]dolla.Income, 0)
-	for rows.Next() {
-		var income dolla.Income
-		if err := rows.StructScan(&income); err != nil {
-			return dolla.IncomePage{}, err
+	if rows.Next() {
+		var document dolla.StatementDocument
+		if err := rows.StructScan(&document); err != nil {
+			return dolla.StatementDocument{}, err
 		}
 
-		incomes = append(incomes, income)
+		return document, nil
 	}
 
-	tq := `SELECT COUNT(*) FROM incomes WHERE user_id = $1`
-	rows, err = r.db.QueryxContext(ctx, tq, userID)
+	return dolla.StatementDocument{}, errors.New("statement document not found")
+}
+
+func (r *sqlite3) CreateCSVMapping(ctx context.Context, preset dolla.CSVMappingPreset) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return dolla.IncomePage{}, err
+		return err
 	}
 
-	total := uint64(0)
-	if rows.Next() {
-		if err := rows.Scan(&total); err != nil {
-			return dolla.IncomePage{}, err
+	replaceQuery := `UPDATE csv_mappings SET active = false WHERE user_id = $1 AND name = $2 AND active = true`
+	if _, err := tx.ExecContext(ctx, replaceQuery, preset.UserID, preset.Name); err != nil {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
 		}
+
+		return err
 	}
 
-	return dolla.IncomePage{
-		Offset:  query.Offset,
-		Limit:   query.Limit,
-		Total:   total,
-		Incomes: incomes,
-	}, nil
-}
+	query := `INSERT INTO csv_mappings
+		(id, date_created, created_by, date_updated, updated_by, active, meta, user_id, name, mapping)
+		VALUES (:id, :date_created, :created_by, :date_updated, :updated_by,
+		:active, :meta, :user_id, :name, :mapping)`
+	if _, err := tx.NamedExecContext(ctx, query, preset); err != nil {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
 
-func (r *sqlite3) UpdateIncome(ctx context.Context, income dolla.Income) error { //nolint:cyclop
-	queries := []string{
-		`date_updated = :date_updated`,
-	}
-	if !income.Date.Time.IsZero() {
-		queries = append(queries, `date = :date`)
-	}
-	if income.Source != "" {
-		queries = append(queries, `source = :source`)
-	}
-	if income.Category != "" {
-		queries = append(queries, `category = :category`)
-	}
-	if income.Description != "" {
-		queries = append(queries, `description = :description`)
-	}
-	if income.PaymentMethod != "" {
-		queries = append(queries, `payment_method = :payment_method`)
-	}
-	if income.Amount != 0 {
-		queries = append(queries, `amount = :amount`)
+		return err
 	}
-	if income.Currency != "" {
-		queries = append(queries, `currency = :currency`)
+
+	if err := tx.Commit(); err != nil {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
+
+		return err
 	}
-	if income.IsRecurring {
-		queries = append(queries, `is_recurring = :is_recurring`)
+
+	return nil
+}
+
+func (r *sqlite3) GetCSVMapping(ctx context.Context, userID, id string) (dolla.CSVMappingPreset, error) {
+	query := `SELECT * FROM csv_mappings WHERE id = $1 AND user_id = $2 AND active = true`
+	rows, err := r.db.QueryxContext(ctx, query, id, userID)
+	if err != nil {
+		return dolla.CSVMappingPreset{}, err
 	}
-	if income.OriginalAmount != 0 {
-		queries = append(queries, `original_amount = :original_amount`)
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	if rows.Next() {
+		var preset dolla.CSVMappingPreset
+		if err := rows.StructScan(&preset); err != nil {
+			return dolla.CSVMappingPreset{}, err
+		}
+
+		return preset, nil
 	}
-	if income.Status != "" {
-		queries = append(queries, `status = :status`)
+
+	return dolla.CSVMappingPreset{}, errors.New("csv mapping not found")
+}
+
+func (r *sqlite3) ListCSVMappings(ctx context.Context, userID string) ([]dolla.CSVMappingPreset, error) {
+	query := `SELECT * FROM csv_mappings WHERE user_id = $1 AND active = true ORDER BY name`
+	rows, err := r.db.QueryxContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
 	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
 
-	query := fmt.Sprintf(`UPDATE incomes SET %s WHERE id = :id`, strings.Join(queries, ", "))
-	if _, err := r.db.NamedExecContext(ctx, query, income); err != nil {
-		return err
+	presets := make([]dolla.CSVMappingPreset, 0)
+	for rows.Next() {
+		var preset dolla.CSVMappingPreset
+		if err := rows.StructScan(&preset); err != nil {
+			return nil, err
+		}
+		presets = append(presets, preset)
 	}
 
-	return nil
+	return presets, nil
 }
 
-func (r *sqlite3) DeleteIncome(ctx context.Context, userID, id string) error {
-	query := `DELETE FROM incomes WHERE id = $1 AND user_id = $2`
+func (r *sqlite3) DeleteCSVMapping(ctx context.Context, userID, id string) error {
+	query := `UPDATE csv_mappings SET active = false WHERE id = $1 AND user_id = $2`
 	if _, err := r.db.ExecContext(ctx, query, id, userID); err != nil {
 		return err
 	}
@@ -271,30 +1220,40 @@ func (r *sqlite3) DeleteIncome(ctx context.Context, userID, id string) error {
 	return nil
 }
 
-func (r *sqlite3) CreateExpense(ctx context.Context, expenses ...dolla.Expense) error {
-	if len(expenses) == 0 {
-		return nil
-	}
-
+func (r *sqlite3) CreateShortcode(ctx context.Context, shortcode dolla.MpesaShortcode) error {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	for i := range expenses {
-		query := `INSERT INTO expenses
-		(id, date_created, created_by, date_updated, updated_by, active, meta,
-		user_id, date, merchant, category, description, payment_method, amount, status)
-		VALUES (:id, :date_created, :created_by, :date_updated, :updated_by,
-		:active, :meta, :user_id, :date, :merchant, :category, :description, :payment_method,
-		:amount, :status)`
 
-		if _, err := tx.NamedExecContext(ctx, query, expenses[i]); err != nil {
-			if err := tx.Rollback(); err != nil {
-				slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
-			}
+	var registered int
+	existsQuery := `SELECT COUNT(*) FROM mpesa_shortcodes WHERE shortcode = $1 AND active = true`
+	if err := tx.GetContext(ctx, &registered, existsQuery, shortcode.Shortcode); err != nil {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
 
-			return err
+		return err
+	}
+	if registered > 0 {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
+
+		return dolla.ErrShortcodeRegistered
+	}
+
+	query := `INSERT INTO mpesa_shortcodes
+		(id, date_created, created_by, date_updated, updated_by, active, meta,
+		user_id, shortcode, payment_method, secret_hash)
+		VALUES (:id, :date_created, :created_by, :date_updated, :updated_by, :active, :meta,
+		:user_id, :shortcode, :payment_method, :secret_hash)`
+	if _, err := tx.NamedExecContext(ctx, query, shortcode); err != nil {
+		if err := tx.Rollback(); err != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
 		}
+
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -308,11 +1267,11 @@ func (r *sqlite3) CreateExpense(ctx context.Context, expenses ...dolla.Expense)
 	return nil
 }
 
-func (r *sqlite3) GetExpense(ctx context.Context, userID, id string) (dolla.Expense, error) {
-	query := `SELECT * FROM expenses WHERE id = $1 AND user_id = $2`
-	rows, err := r.db.QueryxContext(ctx, query, id, userID)
+func (r *sqlite3) GetShortcode(ctx context.Context, shortcode string) (dolla.MpesaShortcode, error) {
+	query := `SELECT * FROM mpesa_shortcodes WHERE shortcode = $1 AND active = true`
+	rows, err := r.db.QueryxContext(ctx, query, shortcode)
 	if err != nil {
-		return dolla.Expense{}, err
+		return dolla.MpesaShortcode{}, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -321,22 +1280,22 @@ func (r *sqlite3) GetExpense(ctx context.Context, userID, id string) (dolla.Expe
 	}()
 
 	if rows.Next() {
-		var expense dolla.Expense
-		if err := rows.StructScan(&expense); err != nil {
-			return dolla.Expense{}, err
+		var registered dolla.MpesaShortcode
+		if err := rows.StructScan(&registered); err != nil {
+			return dolla.MpesaShortcode{}, err
 		}
 
-		return expense, nil
+		return registered, nil
 	}
 
-	return dolla.Expense{}, errors.New("not found")
+	return dolla.MpesaShortcode{}, errors.New("m-pesa shortcode not found")
 }
 
-func (r *sqlite3) ListExpenses(ctx context.Context, userID string, query dolla.Query) (dolla.ExpensePage, error) {
-	q := fmt.Sprintf(`SELECT * FROM expenses WHERE user_id = $1 LIMIT %d OFFSET %d`, query.Limit, query.Offset)
-	rows, err := r.db.QueryxContext(ctx, q, userID)
+func (r *sqlite3) ListShortcodes(ctx context.Context, userID string) ([]dolla.MpesaShortcode, error) {
+	query := `SELECT * FROM mpesa_shortcodes WHERE user_id = $1 AND active = true ORDER BY shortcode`
+	rows, err := r.db.QueryxContext(ctx, query, userID)
 	if err != nil {
-		return dolla.ExpensePage{}, err
+		return nil, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -344,73 +1303,20 @@ func (r *sqlite3) ListExpenses(ctx context.Context, userID string, query dolla.Q
 		}
 	}()
 
-	expenses := make([]dolla.Expense, 0)
+	shortcodes := make([]dolla.MpesaShortcode, 0)
 	for rows.Next() {
-		var expense dolla.Expense
-		if err := rows.StructScan(&expense); err != nil {
-			return dolla.ExpensePage{}, err
-		}
-
-		expenses = append(expenses, expense)
-	}
-
-	tq := `SELECT COUNT(*) FROM expenses WHERE user_id = $1`
-	rows, err = r.db.QueryxContext(ctx, tq, userID)
-	if err != nil {
-		return dolla.ExpensePage{}, err
-	}
-
-	total := uint64(0)
-	if rows.Next() {
-		if err := rows.Scan(&total); err != nil {
-			return dolla.ExpensePage{}, err
+		var shortcode dolla.MpesaShortcode
+		if err := rows.StructScan(&shortcode); err != nil {
+			return nil, err
 		}
+		shortcodes = append(shortcodes, shortcode)
 	}
 
-	return dolla.ExpensePage{
-		Offset:   query.Offset,
-		Limit:    query.Limit,
-		Total:    total,
-		Expenses: expenses,
-	}, nil
-}
-
-func (r *sqlite3) UpdateExpense(ctx context.Context, expense dolla.Expense) error {
-	queries := []string{
-		`date_updated = :date_updated`,
-	}
-	if !expense.Date.Time.IsZero() {
-		queries = append(queries, `date = :date`)
-	}
-	if expense.Merchant != "" {
-		queries = append(queries, `merchant = :merchant`)
-	}
-	if expense.Category != "" {
-		queries = append(queries, `category = :category`)
-	}
-	if expense.Description != "" {
-		queries = append(queries, `description = :description`)
-	}
-	if expense.PaymentMethod != "" {
-		queries = append(queries, `payment_method = :payment_method`)
-	}
-	if expense.Amount != 0 {
-		queries = append(queries, `amount = :amount`)
-	}
-	if expense.Status != "" {
-		queries = append(queries, `status = :status`)
-	}
-
-	query := fmt.Sprintf(`UPDATE expenses SET %s WHERE id = :id`, strings.Join(queries, ", "))
-	if _, err := r.db.NamedExecContext(ctx, query, expense); err != nil {
-		return err
-	}
-
-	return nil
+	return shortcodes, nil
 }
 
-func (r *sqlite3) DeleteExpense(ctx context.Context, userID, id string) error {
-	query := `DELETE FROM expenses WHERE id = $1 AND user_id = $2`
+func (r *sqlite3) DeleteShortcode(ctx context.Context, userID, id string) error {
+	query := `UPDATE mpesa_shortcodes SET active = false WHERE id = $1 AND user_id = $2`
 	if _, err := r.db.ExecContext(ctx, query, id, userID); err != nil {
 		return err
 	}
@@ -421,7 +1327,7 @@ func (r *sqlite3) DeleteExpense(ctx context.Context, userID, id string) error {
 func (r *sqlite3) GetUserProfile(ctx context.Context, clerkUserID string) (dolla.UserProfile, error) {
 	query := `
 		SELECT id, date_created, created_by, date_updated, updated_by, active, meta,
-		       clerk_user_id, age, life_stage, income_bracket, goals, onboarding_complete
+		       clerk_user_id, age, life_stage, income_bracket, goals, onboarding_complete, country
 		FROM user_profiles 
 		WHERE clerk_user_id = $1 AND active = true
 	`
@@ -443,6 +1349,7 @@ func (r *sqlite3) GetUserProfile(ctx context.Context, clerkUserID string) (dolla
 		&profile.IncomeBracket,
 		&goalsJSON,
 		&profile.OnboardingComplete,
+		&profile.Country,
 	)
 	if err != nil {
 		return dolla.UserProfile{}, err
@@ -469,10 +1376,10 @@ func (r *sqlite3) CreateUserProfile(ctx context.Context, profile dolla.UserProfi
 	query := `
 		INSERT INTO user_profiles (
 			id, date_created, created_by, date_updated, updated_by, active, meta,
-			clerk_user_id, age, life_stage, income_bracket, goals, onboarding_complete
+			clerk_user_id, age, life_stage, income_bracket, goals, onboarding_complete, country
 		) VALUES (
 			:id, :date_created, :created_by, :date_updated, :updated_by, :active, :meta,
-			:clerk_user_id, :age, :life_stage, :income_bracket, :goals, :onboarding_complete
+			:clerk_user_id, :age, :life_stage, :income_bracket, :goals, :onboarding_complete, :country
 		)
 	`
 
@@ -490,6 +1397,7 @@ func (r *sqlite3) CreateUserProfile(ctx context.Context, profile dolla.UserProfi
 		"income_bracket":      profile.IncomeBracket,
 		"goals":               goalsJSON,
 		"onboarding_complete": profile.OnboardingComplete,
+		"country":             profile.Country,
 	})
 	if err != nil {
 		if rbErr := tx.Rollback(); rbErr != nil {
@@ -536,6 +1444,7 @@ func (r *sqlite3) UpdateUserProfile(ctx context.Context, profile dolla.UserProfi
 			income_bracket = :income_bracket,
 			goals = $1,
 			onboarding_complete = :onboarding_complete,
+			country = :country,
 			meta = :meta
 		WHERE id = :id AND active = true
 	`
@@ -548,6 +1457,7 @@ func (r *sqlite3) UpdateUserProfile(ctx context.Context, profile dolla.UserProfi
 		"life_stage":          profile.LifeStage,
 		"income_bracket":      profile.IncomeBracket,
 		"onboarding_complete": profile.OnboardingComplete,
+		"country":             profile.Country,
 		"meta":                profile.Meta,
 	})
 	if err != nil {
@@ -806,6 +1716,53 @@ func (r *sqlite3) GetBudgetSummary(ctx context.Context, userID, month string) (d
 	return summary, nil
 }
 
+func (r *sqlite3) ListMonthlyFees(ctx context.Context, userID, from, to string) ([]dolla.MonthlyFees, error) {
+	query := `
+		SELECT strftime('%Y-%m', date) AS month, payment_method, SUM(fee), COUNT(*)
+		FROM expenses
+		WHERE user_id = $1
+		  AND active = true
+		  AND COALESCE(status, '') != $4
+		  AND fee > 0
+		  AND strftime('%Y-%m', date) BETWEEN $2 AND $3
+		GROUP BY month, payment_method
+		ORDER BY month
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, from, to, dolla.Canceled)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", slog.String("err", err.Error()))
+		}
+	}()
+
+	months := make([]dolla.MonthlyFees, 0)
+	for rows.Next() {
+		var month string
+		var method dolla.PaymentMethod
+		var total float64
+		var count int
+		if err := rows.Scan(&month, &method, &total, &count); err != nil {
+			return nil, err
+		}
+
+		if len(months) == 0 || months[len(months)-1].Month != month {
+			months = append(months, dolla.MonthlyFees{
+				Month:           month,
+				ByPaymentMethod: make(map[dolla.PaymentMethod]float64),
+			})
+		}
+		fees := &months[len(months)-1]
+		fees.Total += total
+		fees.Transactions += count
+		fees.ByPaymentMethod[method] += total
+	}
+
+	return months, rows.Err()
+}
+
 func (r *sqlite3) CalculateBudgetProgress(ctx context.Context, userID, month string) error { //nolint:cyclop
 	// Get all budgets for the month
 	budgets, err := r.ListBudgets(ctx, userID, dolla.Query{Limit: maxBudgets}, month)
@@ -823,17 +1780,22 @@ func (r *sqlite3) CalculateBudgetProgress(ctx context.Context, userID, month str
 	}
 
 	for i := range budgets.Budgets {
+		// Transaction fees count towards the budget of the expense they were charged on, while
+		// reversed expenses, which are canceled, count towards none.
 		spentQuery := `
-			SELECT COALESCE(SUM(amount), 0) 
+			SELECT COALESCE(SUM(amount + fee), 0) 
 			FROM expenses 
 			WHERE user_id = $1 
 			  AND active = true 
 			  AND category = $2 
 			  AND strftime('%Y-%m', date) = $3
+			  AND COALESCE(status, '') != $4
 		`
 
 		var spentAmount float64
-		err := tx.QueryRowContext(ctx, spentQuery, userID, budgets.Budgets[i].Category, month).Scan(&spentAmount)
+		err := tx.QueryRowContext(
+			ctx, spentQuery, userID, budgets.Budgets[i].Category, month, dolla.Canceled,
+		).Scan(&spentAmount)
 		if err != nil {
 			if err := tx.Rollback(); err != nil {
 				slog.Error("failed to rollback transaction", slog.String("err", err.Error()))
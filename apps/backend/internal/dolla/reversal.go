@@ -0,0 +1,139 @@
+package dolla
+
+import (
+	"math"
+	"time"
+)
+
+// reversible is an income or an expense of a parsed statement seen the same way, so that
+// a reversal can be linked to the transaction it undoes whichever direction it went.
+type reversible struct {
+	meta        Metadata
+	status      *Status
+	income      bool
+	amount      float64
+	date        time.Time
+	description string
+}
+
+// linkReversals cancels each reversal together with the transaction it undoes, so that
+// neither counts towards totals, and links the two in their metadata. The original is
+// looked up by the receipt number the reversal names, or otherwise taken to be the latest
+// earlier transaction of the same amount going the other way. A reversal naming a receipt
+// from an earlier statement is canceled here and its original when the batch is imported.
+// Reversals whose original cannot be found are kept so that the balance still adds up.
+func (p *ParsedStatement) linkReversals(
+	isReversal func(description string) bool, reversedReceipt func(description, receipt string) string,
+) {
+	rows := make([]reversible, 0, len(p.Incomes)+len(p.Expenses))
+	for i := range p.Incomes {
+		income := &p.Incomes[i]
+		rows = append(rows, reversible{
+			income.Meta, &income.Status, true, income.Amount, income.Date.Time, income.Description,
+		})
+	}
+	for i := range p.Expenses {
+		expense := &p.Expenses[i]
+		rows = append(rows, reversible{
+			expense.Meta, &expense.Status, false, expense.Amount, expense.Date.Time, expense.Description,
+		})
+	}
+
+	receipts := make(map[string]int)
+	for i := range rows {
+		receipt := transactionReference(rows[i].meta)
+		if _, ok := receipts[receipt]; !ok && receipt != "" && !isReversal(rows[i].description) {
+			receipts[receipt] = i
+		}
+	}
+
+	for i := range rows {
+		reversal := rows[i]
+		if reversal.meta == nil || !isReversal(reversal.description) {
+			continue
+		}
+
+		original := -1
+		receipt := transactionReference(reversal.meta)
+		reversed := reversedReceipt(reversal.description, receipt)
+		j, inBatch := receipts[reversed]
+		switch {
+		case inBatch && rows[j].income != reversal.income:
+			original = j
+		case inBatch:
+			// The receipt is of a transaction going the same way, which a reversal cannot undo.
+			continue
+		case reversed == "":
+			original = reversedRow(rows, reversal, isReversal)
+		}
+
+		switch {
+		case original >= 0:
+			*rows[original].status = Canceled
+			rows[original].meta["reversedBy"] = receipt
+			reversed = transactionReference(rows[original].meta)
+		case reversed == "":
+			continue
+		}
+		*reversal.status = Canceled
+		reversal.meta["reverses"] = reversed
+	}
+}
+
+// reversedRow returns the latest transaction before the reversal that has its amount, went
+// the other way and is not canceled yet, or -1 if there is none.
+func reversedRow(rows []reversible, reversal reversible, isReversal func(description string) bool) int {
+	const epsilon = 0.005
+
+	original := -1
+	for i := range rows {
+		row := rows[i]
+		if row.income == reversal.income || row.meta == nil || *row.status == Canceled ||
+			isReversal(row.description) || row.date.After(reversal.date) ||
+			math.Abs(row.amount-reversal.amount) > epsilon {
+			continue
+		}
+		if original < 0 || !row.date.Before(rows[original].date) {
+			original = i
+		}
+	}
+
+	return original
+}
+
+// earlierReversals returns the batch's reversals of transactions imported from earlier
+// statements, which are canceled when the batch is stored. The batch must be stamped.
+func earlierReversals(userID string, incomes []Income, expenses []Expense) []Reversal {
+	batch := make(map[string]bool)
+	for i := range incomes {
+		batch[transactionReference(incomes[i].Meta)] = true
+	}
+	for i := range expenses {
+		batch[transactionReference(expenses[i].Meta)] = true
+	}
+
+	var reversals []Reversal
+	add := func(meta Metadata, status Status, dedupeKey string, income bool) {
+		reversed, _ := meta["reverses"].(string)
+		if status != Canceled || reversed == "" || batch[reversed] {
+			return
+		}
+
+		reversals = append(reversals, Reversal{
+			UserID:     userID,
+			DedupeKey:  dedupeKey,
+			Income:     income,
+			Receipt:    reversed,
+			ReversedBy: transactionReference(meta),
+		})
+	}
+
+	for i := range incomes {
+		add(incomes[i].Meta, incomes[i].Status, incomes[i].DedupeKey, true)
+	}
+	for i := range expenses {
+		add(expenses[i].Meta, expenses[i].Status, expenses[i].DedupeKey, false)
+	}
+
+	return reversals
+}
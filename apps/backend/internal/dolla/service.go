@@ -4,62 +4,63 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"mime/multipart"
-	"net/http"
+	"sync"
 
 	"github.com/google/uuid"
 )
 
 type service struct {
-	repo            Repository
-	pdfExtractorURL string
-}
-
-func NewService(repo Repository, pdfExtractorURL string) Service {
+	repo      Repository
+	extractor Extractor
+	parsers   *ParserRegistry
+	imports   chan importTask
+	// queued holds the IDs of jobs sent to the workers, or being imported right away,
+	// so that resumeImports never picks them up.
+	queued sync.Map
+	// previews holds the parsed statements awaiting commit by their preview token.
+	previews sync.Map
+}
+
+func NewService(repo Repository, extractor Extractor, parsers *ParserRegistry) Service {
 	return &service{
-		repo:            repo,
-		pdfExtractorURL: pdfExtractorURL,
+		repo:      repo,
+		extractor: extractor,
+		parsers:   parsers,
+		imports:   make(chan importTask, importQueueSize),
 	}
 }
 
-func (s *service) CreateTransaction(
-	ctx context.Context, userID string, ttype Statement, fileHeader *multipart.FileHeader,
-) error {
-	file, err := fileHeader.Open()
-	if err != nil {
-		return err
+// ListStatementTypes lists the statement types offered in the user's country, or every
+// type when userID is empty.
+func (s *service) ListStatementTypes(ctx context.Context, userID string) []StatementType {
+	types := s.parsers.StatementTypes()
+	if userID == "" {
+		return types
 	}
-	defer file.Close()
 
-	switch ttype {
-	case MpesaStatement:
-		incomes, expenses, err := Mpesa(ctx, http.DefaultClient, s.pdfExtractorURL, file)
-		if err != nil {
-			return err
+	pack := s.countryPack(ctx, userID)
+	offered := make([]StatementType, 0, len(types))
+	for i := range types {
+		if pack.Offers(types[i].Type) {
+			offered = append(offered, types[i])
 		}
+	}
 
-		// Set user ID for all transactions
-		for i := range incomes {
-			incomes[i].UserID = userID
-		}
-		for i := range expenses {
-			expenses[i].UserID = userID
-		}
+	return offered
+}
 
-		if err := s.CreateIncome(ctx, incomes...); err != nil {
-			return err
-		}
-		if err := s.CreateExpense(ctx, expenses...); err != nil {
-			return err
-		}
+func (s *service) ListCountryPacks(_ context.Context) []CountryPack {
+	return CountryPacks()
+}
 
-		return nil
-	case IMBankStatement:
-		return fmt.Errorf("unsupported statement type: %s", ttype)
-	default:
-		return fmt.Errorf("unsupported statement type: %s", ttype)
+// countryPack returns the pack of the country in the user's profile.
+func (s *service) countryPack(ctx context.Context, userID string) CountryPack {
+	profile, err := s.repo.GetUserProfile(ctx, userID)
+	if err != nil {
+		return PackFor("")
 	}
+
+	return PackFor(profile.Country)
 }
 
 func (s *service) CreateIncome(ctx context.Context, incomes ...Income) error {
@@ -133,6 +134,13 @@ func (s *service) UpdateUserProfile(ctx context.Context, profile UserProfile) er
 func (s *service) CompleteOnboarding(
 	ctx context.Context, clerkUserID string, req OnboardingRequest,
 ) (OnboardingResponse, error) {
+	if _, ok := countryPacks[req.Country]; req.Country != "" && !ok {
+		return OnboardingResponse{
+			Success: false,
+			Message: "Unsupported country",
+		}, nil
+	}
+
 	existingProfile, err := s.repo.GetUserProfile(ctx, clerkUserID)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return OnboardingResponse{
@@ -146,6 +154,9 @@ func (s *service) CompleteOnboarding(
 		existingProfile.LifeStage = req.LifeStage
 		existingProfile.IncomeBracket = req.IncomeBracket
 		existingProfile.Goals = req.Goals
+		if req.Country != "" {
+			existingProfile.Country = req.Country
+		}
 		existingProfile.OnboardingComplete = true
 		existingProfile.PopulateDataOnUpdate(ctx)
 
@@ -169,6 +180,7 @@ func (s *service) CompleteOnboarding(
 		LifeStage:          req.LifeStage,
 		IncomeBracket:      req.IncomeBracket,
 		Goals:              req.Goals,
+		Country:            PackFor(req.Country).Country,
 		OnboardingComplete: true,
 	}
 	newProfile.ID = uuid.New().String()
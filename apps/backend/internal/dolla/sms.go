@@ -0,0 +1,386 @@
+package dolla
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smsDateLayouts are the day first dates and times of M-Pesa and Airtel Money confirmations.
+var smsDateLayouts = []string{ //nolint:gochecknoglobals
+	"2/1/06 3:04 PM", "2/1/06 3:04PM", "2/1/2006 3:04 PM", "2/1/2006 3:04PM", "2/1/06 15:04", "2/1/2006 15:04",
+}
+
+const smsAmount = `(?:KSH|KES)\.?\s?([\d,]+(?:\.\d{1,2})?)`
+
+var ( //nolint:gochecknoglobals
+	smsSeparator = regexp.MustCompile(`\n\s*\n`)
+	// smsMpesaStart matches the receipt number that opens an M-Pesa confirmation.
+	smsMpesaStart = regexp.MustCompile(`(?i)\b([A-Z0-9]{10})\s+confirmed`)
+	smsAirtelID   = regexp.MustCompile(`(?i)(?:TXN|TRANS(?:ACTION)?)\.?\s?ID:?\s*([A-Z0-9][A-Z0-9.]*[A-Z0-9])`)
+	smsDateTime   = regexp.MustCompile(`(?i)on (\d{1,2}/\d{1,2}/\d{2,4}) at (\d{1,2}:\d{2}\s?(?:[AP]M)?)`)
+	smsBalance    = regexp.MustCompile(`(?i)(?:balance is|bal(?:ance)?:?)\s*` + smsAmount)
+	smsFee        = regexp.MustCompile(`(?i)(?:transaction cost,?|fee:?|charges?:?)\s*` + smsAmount)
+
+	smsReceived = regexp.MustCompile(`(?i)received\s*` + smsAmount + `\s*from (.+?)(?: on \d| Txn| New|\.\s|$)`)
+	smsSent     = regexp.MustCompile(
+		`(?i)` + smsAmount + `\s*sent to (.+?)(?: for account (\S+?))?\.?(?: on \d| Txn| New|$)`,
+	)
+	smsSentAirtel = regexp.MustCompile(`(?i)sent\s*` + smsAmount + `\s*to (.+?)(?: on \d| Txn| New|\.\s|$)`)
+	smsPaid       = regexp.MustCompile(`(?i)` + smsAmount + `\s*paid to (.+?)\.?(?: on \d| Txn| New|$)`)
+	smsWithdrawn  = regexp.MustCompile(`(?i)withdraw\s*` + smsAmount + `\s*from (.+?)(?: New| on \d|$)`)
+	smsAirtime    = regexp.MustCompile(`(?i)bought\s*` + smsAmount + `\s*of airtime`)
+)
+
+// ErrNoSMS is returned when an SMS import holds no transaction confirmations.
+var ErrNoSMS = errors.New("no m-pesa or airtel money confirmations found")
+
+// ErrInvalidSMSBackup is returned when the uploaded file is not an SMS Backup & Restore export.
+var ErrInvalidSMSBackup = errors.New("invalid sms backup")
+
+// smsMessage is a transaction confirmation together with the time the phone received it,
+// which is zero for pasted messages.
+type smsMessage struct {
+	body     string
+	received time.Time
+}
+
+// smsBackup is the XML written by the SMS Backup & Restore app.
+type smsBackup struct {
+	Messages []struct {
+		Address string `xml:"address,attr"`
+		Date    string `xml:"date,attr"`
+		Body    string `xml:"body,attr"`
+	} `xml:"sms"`
+}
+
+// smsTransaction is what a confirmation says happened.
+type smsTransaction struct {
+	amount       float64
+	income       bool
+	counterparty string
+	account      string
+	description  string
+	method       PaymentMethod
+}
+
+func (s *service) ImportSMS(
+	ctx context.Context, userID, text string, backup *multipart.FileHeader,
+) (ImportJob, error) {
+	messages := splitSMS(text)
+	fileName := "pasted messages"
+	if backup != nil {
+		file, err := backup.Open()
+		if err != nil {
+			return ImportJob{}, err
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return ImportJob{}, err
+		}
+
+		backed, err := parseSMSBackup(data)
+		if err != nil {
+			return ImportJob{}, err
+		}
+		messages = append(messages, backed...)
+		fileName = backup.Filename
+	}
+	if len(messages) == 0 {
+		return ImportJob{}, ErrNoSMS
+	}
+
+	job := ImportJob{
+		UserID:    userID,
+		Statement: SMSStatement,
+		FileName:  fileName,
+		Status:    ImportSaving,
+	}
+	job.PopulateDataOnCreate(ctx)
+	s.queued.Store(job.ID, struct{}{})
+	defer s.queued.Delete(job.ID)
+	if err := s.repo.CreateImportJob(ctx, job); err != nil {
+		return ImportJob{}, err
+	}
+
+	result, err := s.importTransactions(ctx, userID, job.ID, parseSMS(messages, s.countryPack(ctx, userID)))
+	if err != nil {
+		s.failImport(ctx, &job, err)
+
+		return ImportJob{}, err
+	}
+
+	job.Result = &result
+	s.setImportStatus(ctx, &job, ImportDone)
+
+	return job, nil
+}
+
+// splitSMS splits pasted text into confirmations. Messages are separated by blank lines
+// or, when pasted back to back, by the receipt number opening each M-Pesa confirmation.
+func splitSMS(text string) []smsMessage {
+	var messages []smsMessage
+	for _, chunk := range smsSeparator.Split(strings.ReplaceAll(text, "\r\n", "\n"), -1) {
+		starts := smsMpesaStart.FindAllStringIndex(chunk, -1)
+		if len(starts) < 2 {
+			if chunk = strings.TrimSpace(chunk); chunk != "" {
+				messages = append(messages, smsMessage{body: chunk})
+			}
+
+			continue
+		}
+
+		if head := strings.TrimSpace(chunk[:starts[0][0]]); head != "" {
+			messages = append(messages, smsMessage{body: head})
+		}
+		for i, start := range starts {
+			end := len(chunk)
+			if i+1 < len(starts) {
+				end = starts[i+1][0]
+			}
+			messages = append(messages, smsMessage{body: strings.TrimSpace(chunk[start[0]:end])})
+		}
+	}
+
+	return messages
+}
+
+// parseSMSBackup returns the M-Pesa and Airtel Money messages of an SMS Backup & Restore export.
+func parseSMSBackup(file []byte) ([]smsMessage, error) {
+	var backup smsBackup
+	if err := xml.Unmarshal(file, &backup); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSMSBackup, err)
+	}
+
+	var messages []smsMessage
+	for _, sms := range backup.Messages {
+		sender := strings.ToUpper(sms.Address)
+		if !strings.Contains(sender, "MPESA") && !strings.Contains(sender, "M-PESA") &&
+			!strings.Contains(sender, "AIRTEL") {
+			continue
+		}
+
+		message := smsMessage{body: strings.TrimSpace(sms.Body)}
+		if millis, err := strconv.ParseInt(sms.Date, 10, 64); err == nil {
+			message.received = time.UnixMilli(millis).UTC()
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func parseSMS(messages []smsMessage, pack CountryPack) ParsedStatement {
+	var parsed ParsedStatement
+	for row, message := range messages {
+		parseSMSMessage(&parsed, row, message, pack)
+	}
+	// Only confirmations in Kenyan shillings are recognised.
+	parsed.issuedIn(Kenya)
+
+	return parsed
+}
+
+func parseSMSMessage(parsed *ParsedStatement, row int, message smsMessage, pack CountryPack) { //nolint:cyclop
+	body := normaliseNarration(message.body)
+
+	airtel := false
+	var receipt string
+	if match := smsMpesaStart.FindStringSubmatch(body); match != nil {
+		receipt = strings.ToUpper(match[1])
+	} else if match := smsAirtelID.FindStringSubmatch(body); match != nil {
+		receipt = strings.ToUpper(match[1])
+		airtel = true
+	} else {
+		parsed.reject(0, row, "not an m-pesa or airtel money confirmation")
+
+		return
+	}
+
+	txn, ok := parseSMSTransaction(body, airtel)
+	if !ok {
+		parsed.reject(0, row, "unrecognised confirmation %s", receipt)
+
+		return
+	}
+
+	date := message.received
+	if match := smsDateTime.FindStringSubmatch(body); match != nil {
+		parsedDate, err := parseDate(match[1]+" "+strings.ToUpper(match[2]), smsDateLayouts...)
+		if err != nil {
+			parsed.reject(0, row, "invalid date %q", match[1]+" "+match[2])
+
+			return
+		}
+		date = parsedDate
+	}
+	if date.IsZero() {
+		parsed.reject(0, row, "missing date in confirmation %s", receipt)
+
+		return
+	}
+
+	meta := Metadata{
+		"receiptNo":      receipt,
+		"completionTime": date.Format(time.DateTime),
+		"counterparty":   txn.counterparty,
+		"source":         "sms",
+	}
+	if match := smsBalance.FindStringSubmatch(body); match != nil {
+		if balance, err := parseAmount(match[1]); err == nil {
+			meta["balance"] = balance
+		}
+	}
+	if txn.account != "" {
+		meta["account"] = txn.account
+	}
+
+	if txn.income {
+		parsed.Incomes = append(parsed.Incomes, Income{
+			BaseEntity:     BaseEntity{Meta: meta},
+			Date:           Date{date},
+			Source:         txn.counterparty,
+			Category:       pack.Category(txn.description),
+			Description:    txn.description,
+			PaymentMethod:  txn.method,
+			Amount:         txn.amount,
+			IsRecurring:    isRecurringTransaction(txn.description),
+			OriginalAmount: txn.amount,
+			Status:         Imported,
+		})
+	} else {
+		parsed.Expenses = append(parsed.Expenses, Expense{
+			BaseEntity:    BaseEntity{Meta: meta},
+			Date:          Date{date},
+			Merchant:      txn.counterparty,
+			Category:      pack.Category(txn.description),
+			Description:   txn.description,
+			PaymentMethod: txn.method,
+			Amount:        txn.amount,
+			Status:        Imported,
+		})
+	}
+
+	// The transaction cost is the fee of a payment, or a separate expense sharing the
+	// receipt number when charged on incoming money.
+	if match := smsFee.FindStringSubmatch(body); match != nil {
+		fee, err := parseAmount(match[1])
+		if err == nil && fee > 0 && !txn.income {
+			parsed.Expenses[len(parsed.Expenses)-1].Fee = fee
+		} else if err == nil && fee > 0 {
+			provider, method := "M-PESA", MpesaOnline
+			if airtel {
+				provider, method = "AIRTEL MONEY", AirtelMoney
+			}
+			parsed.Expenses = append(parsed.Expenses, Expense{
+				BaseEntity: BaseEntity{Meta: Metadata{
+					"receiptNo":      receipt,
+					"completionTime": date.Format(time.DateTime),
+					"feeFor":         txn.description,
+					"charge":         true,
+					"source":         "sms",
+				}},
+				Date:          Date{date},
+				Merchant:      provider,
+				Category:      OtherCategory,
+				Description:   provider + " transaction cost",
+				PaymentMethod: method,
+				Amount:        fee,
+				Status:        Imported,
+			})
+		}
+	}
+}
+
+// parseSMSTransaction reads the amount, direction and counterparty of a confirmation.
+func parseSMSTransaction(body string, airtel bool) (smsTransaction, bool) { //nolint:cyclop
+	var txn smsTransaction
+	var amount string
+
+	sendMethod, receiveMethod := MpesaSendMoney, MpesaSendMoney
+	if airtel {
+		sendMethod, receiveMethod = AirtelMoney, AirtelMoney
+	}
+
+	if match := smsReceived.FindStringSubmatch(body); match != nil {
+		amount, txn.counterparty = match[1], smsCounterparty(match[2])
+		txn.income, txn.method = true, receiveMethod
+		txn.description = "Received from " + txn.counterparty
+	} else if match := smsSent.FindStringSubmatch(body); match != nil {
+		amount, txn.counterparty, txn.account = match[1], smsCounterparty(match[2]), match[3]
+		txn.method = sendMethod
+		txn.description = "Sent to " + txn.counterparty
+		if txn.account != "" {
+			txn.method = MpesaPaybill
+			txn.description = "Pay Bill to " + txn.counterparty + " account " + txn.account
+		}
+	} else if match := smsSentAirtel.FindStringSubmatch(body); match != nil {
+		amount, txn.counterparty = match[1], smsCounterparty(match[2])
+		txn.method = sendMethod
+		txn.description = "Sent to " + txn.counterparty
+	} else if match := smsPaid.FindStringSubmatch(body); match != nil {
+		amount, txn.counterparty = match[1], smsCounterparty(match[2])
+		txn.method = MpesaTill
+		if airtel {
+			txn.method = AirtelMoney
+		}
+		txn.description = "Buy Goods at " + txn.counterparty
+	} else if match := smsWithdrawn.FindStringSubmatch(body); match != nil {
+		amount, txn.counterparty = match[1], smsCounterparty(match[2])
+		txn.method = Cash
+		txn.description = "Cash withdrawal at " + txn.counterparty
+	} else if match := smsAirtime.FindStringSubmatch(body); match != nil {
+		amount, txn.counterparty = match[1], "AIRTIME"
+		txn.method = MpesaOnline
+		if airtel {
+			txn.method = AirtelMoney
+		}
+		txn.description = "Airtime purchase"
+	} else {
+		return smsTransaction{}, false
+	}
+
+	value, err := parseAmount(amount)
+	if err != nil || value <= 0 {
+		return smsTransaction{}, false
+	}
+	txn.amount = value
+
+	return txn, true
+}
+
+// smsCounterparty returns the name in "JOHN DOE 0712345678", dropping the phone number,
+// or the phone number when there is no name.
+func smsCounterparty(value string) string {
+	fields := strings.Fields(strings.ToUpper(strings.TrimRight(strings.TrimSpace(value), ".")))
+
+	var name, phone []string
+	for _, field := range fields {
+		if isPhoneNumber(field) || strings.Contains(field, "***") {
+			phone = append(phone, field)
+
+			continue
+		}
+		name = append(name, field)
+	}
+
+	// Agents are written "123456 - AGENT NAME".
+	counterparty := strings.Join(name, " ")
+	if number, agent, found := strings.Cut(counterparty, " - "); found && isDigits(number) {
+		counterparty = agent
+	}
+	if counterparty == "" {
+		counterparty = strings.Join(phone, " ")
+	}
+
+	return counterparty
+}
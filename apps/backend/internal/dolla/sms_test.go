@@ -0,0 +1,44 @@
+package dolla
+
+import "testing"
+
+func TestParseSMS(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		fixture string
+		golden  string
+		read    func(t *testing.T, file []byte) []smsMessage
+	}{
+		{
+			fixture: "sms.txt",
+			golden:  "sms.golden",
+			read: func(_ *testing.T, file []byte) []smsMessage {
+				return splitSMS(string(file))
+			},
+		},
+		{
+			fixture: "sms_backup.xml",
+			golden:  "sms_backup.golden",
+			read: func(t *testing.T, file []byte) []smsMessage {
+				t.Helper()
+
+				messages, err := parseSMSBackup(file)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				return messages
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			t.Parallel()
+
+			messages := tc.read(t, readFixture(t, tc.fixture))
+			checkGolden(t, tc.golden, parseSMS(messages, PackFor(Kenya)))
+		})
+	}
+}
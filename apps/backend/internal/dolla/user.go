@@ -43,6 +43,7 @@ type UserProfile struct {
 	LifeStage          LifeStage       `db:"life_stage"          json:"life_stage"`
 	IncomeBracket      IncomeBracket   `db:"income_bracket"      json:"income_bracket"`
 	Goals              []FinancialGoal `db:"goals"               json:"goals"`
+	Country            Country         `db:"country"             json:"country"`
 	OnboardingComplete bool            `db:"onboarding_complete" json:"onboarding_complete"`
 }
 
@@ -88,6 +89,7 @@ type OnboardingRequest struct {
 	LifeStage     LifeStage       `json:"life_stage"`
 	IncomeBracket IncomeBracket   `json:"income_bracket"`
 	Goals         []FinancialGoal `json:"goals"`
+	Country       Country         `json:"country"`
 }
 
 type OnboardingResponse struct {
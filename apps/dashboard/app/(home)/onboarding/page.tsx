@@ -30,6 +30,7 @@ type LifeStage =
   | "pre_retirement"
   | "retired";
 type IncomeBracket = "low" | "mid" | "high" | "varies";
+type Country = "KE" | "UG" | "TZ";
 type FinancialGoal =
   | "emergency_fund"
   | "debt_payoff"
@@ -46,8 +47,15 @@ interface OnboardingRequest {
   life_stage: LifeStage;
   income_bracket: IncomeBracket;
   goals: FinancialGoal[];
+  country: Country;
 }
 
+const countryOptions = [
+  { value: "KE", label: "Kenya" },
+  { value: "UG", label: "Uganda" },
+  { value: "TZ", label: "Tanzania" },
+];
+
 const lifeStageOptions = [
   {
     value: "student",
@@ -154,6 +162,7 @@ export default function OnboardingPage() {
     life_stage: "early_career",
     income_bracket: "mid",
     goals: [],
+    country: "KE",
   });
 
   const handleGoalToggle = (goal: FinancialGoal) => {
@@ -315,6 +324,31 @@ export default function OnboardingPage() {
                     </SelectContent>
                   </Select>
                 </div>
+                <div>
+                  <Label htmlFor="country" className="text-base font-medium">
+                    Which country do you live in?
+                  </Label>
+                  <Select
+                    value={formData.country}
+                    onValueChange={(value) =>
+                      setFormData((prev) => ({
+                        ...prev,
+                        country: value as Country,
+                      }))
+                    }
+                  >
+                    <SelectTrigger id="country" className="mt-2">
+                      <SelectValue placeholder="Select your country" />
+                    </SelectTrigger>
+                    <SelectContent>
+                      {countryOptions.map((option) => (
+                        <SelectItem key={option.value} value={option.value}>
+                          {option.label}
+                        </SelectItem>
+                      ))}
+                    </SelectContent>
+                  </Select>
+                </div>
               </div>
             )}
 
@@ -20,9 +20,54 @@ import {
   SelectValue,
 } from "@workspace/ui/components/select";
 import { CirclePlus, Loader2, Upload } from "lucide-react";
-import { useState } from "react";
+import { useEffect, useState } from "react";
 import { toast } from "sonner";
-import { uploadStatement } from "@/lib/api";
+import {
+  getImportJob,
+  getStatementTypes,
+  type ImportJob,
+  type StatementType,
+  uploadStatement,
+} from "@/lib/api";
+
+const POLL_INTERVAL_MS = 2000;
+const ACCEPTED_FILE_TYPES = /\.(pdf|ofx|qfx|qif)$/i;
+
+async function waitForImport(job: ImportJob): Promise<ImportJob> {
+  let current = job;
+  while (current.status !== "done" && current.status !== "failed") {
+    await new Promise((resolve) => setTimeout(resolve, POLL_INTERVAL_MS));
+    current = await getImportJob(current.id);
+  }
+  return current;
+}
+
+function describeImportFailure(
+  job: ImportJob,
+  statementTypes: StatementType[],
+): string {
+  switch (job.errorCode) {
+    case "invalid_password":
+      return "The statement is locked. Enter its password and try again.";
+    case "undetected_statement": {
+      const names = (job.candidates ?? []).map(
+        (type) =>
+          statementTypes.find((statement) => statement.type === type)?.name ??
+          type,
+      );
+      return `Could not tell which statement this is. Select its type from: ${names.join(", ")}.`;
+    }
+    case "statement_rejected":
+      return "The file could not be read. Check that it is a valid statement.";
+    case "extractor_timeout":
+    case "extractor_unavailable":
+      return "Statements cannot be read right now. Please try again later.";
+    case "interrupted":
+      return "The import was interrupted. Please upload the statement again.";
+    default:
+      return job.error || "The statement could not be imported.";
+  }
+}
 
 interface UploadStatementDialogProps {
   onUploadComplete?: () => void;
@@ -33,17 +78,34 @@ export function UploadStatementDialog({
 }: UploadStatementDialogProps) {
   const [open, setOpen] = useState(false);
   const [file, setFile] = useState<File | null>(null);
-  const [statementType, setStatementType] = useState<"mpesa" | "imbank">(
-    "mpesa",
-  );
+  const [statementType, setStatementType] = useState("mpesa");
+  const [statementTypes, setStatementTypes] = useState<StatementType[]>([]);
+  const [password, setPassword] = useState("");
   const [isUploading, setIsUploading] = useState(false);
 
+  useEffect(() => {
+    if (!open) {
+      return;
+    }
+
+    getStatementTypes()
+      .then(setStatementTypes)
+      .catch((error) => {
+        toast("Failed to load statement types", {
+          description:
+            error instanceof Error
+              ? error.message
+              : "An error occurred while loading statement types.",
+        });
+      });
+  }, [open]);
+
   const handleFileChange = (event: React.ChangeEvent<HTMLInputElement>) => {
     const selectedFile = event.target.files?.[0];
     if (selectedFile) {
-      if (selectedFile.type !== "application/pdf") {
+      if (!ACCEPTED_FILE_TYPES.test(selectedFile.name)) {
         toast("Invalid file type", {
-          description: "Please select a PDF file.",
+          description: "Please select a PDF, OFX, QFX or QIF file.",
         });
         return;
       }
@@ -61,13 +123,22 @@ export function UploadStatementDialog({
 
     setIsUploading(true);
     try {
-      await uploadStatement(file, statementType);
+      const job = await waitForImport(
+        await uploadStatement(file, statementType, password),
+      );
+      if (job.status === "failed" || !job.result) {
+        throw new Error(describeImportFailure(job, statementTypes));
+      }
+      const result = job.result;
+      const period = result.period
+        ? ` covering ${result.period.from} to ${result.period.to}`
+        : "";
       toast("Upload successful", {
-        description:
-          "Your statement has been processed successfully. Transactions have been imported.",
+        description: `Imported ${result.incomesCreated} incomes and ${result.expensesCreated} expenses${period}. Skipped ${result.duplicatesSkipped} duplicates and ${result.rejected.length} unreadable rows.`,
       });
       setOpen(false);
       setFile(null);
+      setPassword("");
       onUploadComplete?.();
     } catch (error) {
       toast("Upload failed", {
@@ -104,18 +175,17 @@ export function UploadStatementDialog({
             </Label>
             <Select
               value={statementType}
-              onValueChange={(value: "mpesa" | "imbank") =>
-                setStatementType(value)
-              }
+              onValueChange={(value: string) => setStatementType(value)}
             >
               <SelectTrigger className="col-span-3">
                 <SelectValue placeholder="Select statement type" />
               </SelectTrigger>
               <SelectContent>
-                <SelectItem value="mpesa">M-Pesa Statement</SelectItem>
-                <SelectItem value="imbank" disabled>
-                  I&M Bank Statement (Coming Soon)
-                </SelectItem>
+                {statementTypes.map((statement) => (
+                  <SelectItem key={statement.type} value={statement.type}>
+                    {statement.name}
+                  </SelectItem>
+                ))}
               </SelectContent>
             </Select>
           </div>
@@ -127,7 +197,7 @@ export function UploadStatementDialog({
               <Input
                 id="file"
                 type="file"
-                accept="application/pdf"
+                accept="application/pdf,.pdf,.ofx,.qfx,.qif"
                 onChange={handleFileChange}
                 className="cursor-pointer"
               />
@@ -138,6 +208,20 @@ export function UploadStatementDialog({
               )}
             </div>
           </div>
+          <div className="grid grid-cols-4 items-center gap-4">
+            <Label htmlFor="password" className="text-right">
+              Password
+            </Label>
+            <Input
+              id="password"
+              type="password"
+              autoComplete="off"
+              placeholder="Only for locked statements"
+              value={password}
+              onChange={(event) => setPassword(event.target.value)}
+              className="col-span-3"
+            />
+          </div>
         </div>
         <DialogFooter>
           <Button
@@ -13,6 +13,9 @@ export const paymentMethods = [
   "airtel money",
   "equitel money",
   "t-kash",
+  "mtn momo",
+  "tigo pesa",
+  "halopesa",
   "paypal",
   "google pay",
   "apple pay",
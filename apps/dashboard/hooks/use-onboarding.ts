@@ -9,6 +9,7 @@ interface UserProfile {
   life_stage: string;
   income_bracket: string;
   goals: string[];
+  country: string;
   onboarding_complete: boolean;
 }
 
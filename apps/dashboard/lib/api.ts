@@ -85,10 +85,81 @@ export async function getExpenses(
   return data;
 }
 
+export interface StatementType {
+  type: string;
+  name: string;
+}
+
+export async function getStatementTypes(): Promise<StatementType[]> {
+  const response = await fetch(`${API_BASE_URL}/transactions/types`, {
+    headers: await getAuthHeaders(),
+  });
+
+  if (!response.ok) {
+    throw new Error(
+      `Failed to fetch statement types: ${response.statusText}`,
+    );
+  }
+
+  const data = await response.json();
+  return data;
+}
+
+export interface ImportResult {
+  incomesCreated: number;
+  expensesCreated: number;
+  duplicatesSkipped: number;
+  rejected: { page: number; row: number; reason: string }[];
+  period?: { from: string; to: string };
+  balances?: { opening: number; closing: number; currency?: string };
+  balanceGaps?: {
+    page: number;
+    row: number;
+    receiptNo?: string;
+    expected: number;
+    balance: number;
+  }[];
+  warnings?: string[];
+}
+
+export type ImportErrorCode =
+  | "invalid_password"
+  | "undetected_statement"
+  | "unsupported_statement"
+  | "statement_rejected"
+  | "extractor_timeout"
+  | "extractor_unavailable"
+  | "interrupted";
+
+export interface ImportJob {
+  id: string;
+  statement: string;
+  fileName: string;
+  status: "queued" | "extracting" | "parsing" | "saving" | "done" | "failed";
+  error?: string;
+  errorCode?: ImportErrorCode;
+  candidates?: string[];
+  result?: ImportResult;
+}
+
+export async function getImportJob(id: string): Promise<ImportJob> {
+  const response = await fetch(`${API_BASE_URL}/imports/${id}`, {
+    headers: await getAuthHeaders(),
+  });
+
+  if (!response.ok) {
+    throw new Error(`Failed to fetch import: ${response.statusText}`);
+  }
+
+  const data = await response.json();
+  return data;
+}
+
 export async function uploadStatement(
   file: File,
-  type: "mpesa" | "imbank" = "mpesa",
-): Promise<{ message: string }> {
+  type = "mpesa",
+  password?: string,
+): Promise<ImportJob> {
   const { userId } = await auth();
   if (!userId) {
     throw new Error("User not authenticated");
@@ -96,6 +167,9 @@ export async function uploadStatement(
 
   const formData = new FormData();
   formData.append("file", file);
+  if (password) {
+    formData.append("password", password);
+  }
 
   const response = await fetch(`${API_BASE_URL}/transactions/${type}`, {
     method: "POST",
@@ -106,7 +180,10 @@ export async function uploadStatement(
   });
 
   if (!response.ok) {
-    throw new Error(`Failed to upload statement: ${response.statusText}`);
+    const body = await response.json().catch(() => ({}));
+    throw new Error(
+      body.error || `Failed to upload statement: ${response.statusText}`,
+    );
   }
 
   const data = await response.json();
@@ -378,31 +455,38 @@ export async function getDashboardTotals(): Promise<DashboardTotals> {
         getExpenses(0, 10000),
       ]);
 
+    // Reversed transactions are canceled and left out of every total.
+    const counted = <T extends { status?: string }>(records: T[]) =>
+      records.filter((record) => record.status !== "canceled");
+    // Charges such as M-Pesa transaction costs are kept as fees on their expenses and
+    // count towards what was spent, as they do in budget progress.
+    const spent = (expense: Expense) => expense.amount + (expense.fee ?? 0);
+
     // Calculate totals for all time
-    const totalIncome = currentIncomes.incomes.reduce(
+    const totalIncome = counted(currentIncomes.incomes).reduce(
       (sum, income) => sum + income.amount,
       0,
     );
-    const totalExpenses = currentExpenses.expenses.reduce(
-      (sum, expense) => sum + expense.amount,
+    const totalExpenses = counted(currentExpenses.expenses).reduce(
+      (sum, expense) => sum + spent(expense),
       0,
     );
     const totalBalance = totalIncome - totalExpenses;
     const totalSavings = totalBalance > 0 ? totalBalance : 0;
 
     // Calculate current month totals
-    const currentMonthIncomeList = currentIncomes.incomes.filter((income) =>
-      income.date.startsWith(currentMonth),
+    const currentMonthIncomeList = counted(currentIncomes.incomes).filter(
+      (income) => income.date.startsWith(currentMonth),
     );
-    const currentMonthExpenseList = currentExpenses.expenses.filter((expense) =>
-      expense.date.startsWith(currentMonth),
+    const currentMonthExpenseList = counted(currentExpenses.expenses).filter(
+      (expense) => expense.date.startsWith(currentMonth),
     );
 
     // Calculate previous month totals
-    const previousMonthIncomeList = previousIncomes.incomes.filter((income) =>
-      income.date.startsWith(previousMonth),
+    const previousMonthIncomeList = counted(previousIncomes.incomes).filter(
+      (income) => income.date.startsWith(previousMonth),
     );
-    const previousMonthExpenseList = previousExpenses.expenses.filter(
+    const previousMonthExpenseList = counted(previousExpenses.expenses).filter(
       (expense) => expense.date.startsWith(previousMonth),
     );
 
@@ -411,7 +495,7 @@ export async function getDashboardTotals(): Promise<DashboardTotals> {
       0,
     );
     const currentMonthExpenseTotal = currentMonthExpenseList.reduce(
-      (sum, expense) => sum + expense.amount,
+      (sum, expense) => sum + spent(expense),
       0,
     );
     const currentMonthBalance = currentMonthIncome - currentMonthExpenseTotal;
@@ -423,7 +507,7 @@ export async function getDashboardTotals(): Promise<DashboardTotals> {
       0,
     );
     const previousMonthExpenseTotal = previousMonthExpenseList.reduce(
-      (sum, expense) => sum + expense.amount,
+      (sum, expense) => sum + spent(expense),
       0,
     );
     const previousMonthBalance =
@@ -11,6 +11,7 @@ export const expenseSchema = z.object({
   description: z.string(),
   paymentMethod: z.enum(paymentMethods as [string, ...string[]]),
   amount: z.number(),
+  fee: z.number().optional(),
   status: z.string(),
   dateCreated: z.string().optional(),
   createdBy: z.string().optional(),
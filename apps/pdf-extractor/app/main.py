@@ -1,7 +1,7 @@
 import pdfplumber
 import pandas as pd
 import json
-from fastapi import FastAPI, UploadFile, HTTPException
+from fastapi import FastAPI, Form, UploadFile, HTTPException
 from fastapi.responses import FileResponse
 from typing import Optional
 import uuid
@@ -11,6 +11,9 @@ from starlette.background import BackgroundTask
 import logging
 from concurrent.futures import ProcessPoolExecutor
 import asyncio
+from pdfminer.pdfdocument import PDFPasswordIncorrect
+from pdfminer.psparser import PSException
+from pdfplumber.utils.exceptions import PdfminerException
 
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
@@ -25,12 +28,35 @@ os.makedirs(OUTPUT_DIR, exist_ok=True)
 max_workers = os.cpu_count() or 1
 executor = ProcessPoolExecutor(max_workers=max_workers)
 
+# Tells a wrong password apart from FastAPI's own 422 validation errors.
+PASSWORD_ERROR_CODE = "invalid_password"
 
-def sync_extract_text(pdf_path: str):
+
+class PasswordError(Exception):
+    """Raised when a PDF is encrypted and the password is missing or wrong"""
+
+
+class UnreadablePDFError(Exception):
+    """Raised when the upload is not a PDF pdfplumber can read"""
+
+
+def is_password_error(error: Exception) -> bool:
+    if isinstance(error, PDFPasswordIncorrect):
+        return True
+    if isinstance(error, PdfminerException) and error.args:
+        return isinstance(error.args[0], PDFPasswordIncorrect)
+    return False
+
+
+def is_unreadable_error(error: Exception) -> bool:
+    return isinstance(error, (PdfminerException, PSException))
+
+
+def sync_extract_text(pdf_path: str, password: Optional[str] = None):
     """Synchronous PDF extraction function to run in process pool"""
     structured_data = []
     try:
-        with pdfplumber.open(pdf_path) as pdf:
+        with pdfplumber.open(pdf_path, password=password or "") as pdf:
             for i, page in enumerate(pdf.pages):
                 text = page.extract_text()
                 tables = page.extract_tables()
@@ -50,6 +76,12 @@ def sync_extract_text(pdf_path: str):
                 )
         return structured_data
     except Exception as e:
+        if is_password_error(e):
+            logger.warning(f"Incorrect or missing password for {pdf_path}")
+            raise PasswordError() from None
+        if is_unreadable_error(e):
+            logger.warning(f"Unreadable PDF {pdf_path}: {str(e)}")
+            raise UnreadablePDFError() from None
         logger.error(f"Error processing {pdf_path}: {str(e)}")
         raise
 
@@ -80,12 +112,14 @@ def sync_save_output(data, output_path: str, output_format: str):
         raise
 
 
-async def process_pdf(file_path: str, output_format: str, job_id: str):
+async def process_pdf(
+    file_path: str, output_format: str, job_id: str, password: Optional[str] = None
+):
     """Async wrapper for PDF processing"""
     try:
         loop = asyncio.get_running_loop()
         extracted_data = await loop.run_in_executor(
-            executor, sync_extract_text, file_path
+            executor, sync_extract_text, file_path, password
         )
 
         output_filename = f"{job_id}.{output_format}"
@@ -100,6 +134,8 @@ async def process_pdf(file_path: str, output_format: str, job_id: str):
         )
 
         return output_path
+    except (PasswordError, UnreadablePDFError):
+        raise
     except Exception as e:
         logger.error(f"Error in job {job_id}: {str(e)}")
         raise
@@ -120,8 +156,14 @@ async def extract_from_pdf(
     file: UploadFile,
     output_format: Optional[str] = "json",
     callback_url: Optional[str] = None,
+    password: Optional[str] = Form(None),
 ):
-    """Endpoint for PDF extraction"""
+    """Endpoint for PDF extraction.
+
+    Encrypted PDFs are unlocked with the optional password form field, which is
+    never logged or persisted. A missing or wrong password returns 422 with the
+    detail code "invalid_password". A file that is not a readable PDF returns 400.
+    """
     if output_format is None:
         output_format = "json"
 
@@ -138,7 +180,7 @@ async def extract_from_pdf(
         async with aiofiles.open(upload_path, "wb") as f:
             await f.write(await file.read())
 
-        output_path = await process_pdf(upload_path, output_format, job_id)
+        output_path = await process_pdf(upload_path, output_format, job_id, password)
 
         if callback_url:
             logger.info(f"Would callback to {callback_url} for job {job_id}")
@@ -162,6 +204,18 @@ async def extract_from_pdf(
                 output_path,
             ),
         )
+    except PasswordError:
+        await cleanup_files(upload_path)
+        raise HTTPException(
+            422,
+            detail={
+                "code": PASSWORD_ERROR_CODE,
+                "message": "Incorrect or missing PDF password",
+            },
+        )
+    except UnreadablePDFError:
+        await cleanup_files(upload_path)
+        raise HTTPException(400, detail="The file is not a readable PDF")
     except Exception as e:
         await cleanup_files(upload_path)
         raise HTTPException(500, detail=str(e))